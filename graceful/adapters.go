@@ -0,0 +1,57 @@
+package graceful
+
+import (
+	"context"
+	"io"
+)
+
+// ShutdownFunc adapts a function to Shutdownable
+type ShutdownFunc func(context.Context) error
+
+// Shutdown calls f
+func (f ShutdownFunc) Shutdown(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Disconnector is implemented by clients that close with Disconnect, like *mongo.Client
+type Disconnector interface {
+	Disconnect(context.Context) error
+}
+
+// FromDisconnector adapts a Disconnector to Shutdownable
+func FromDisconnector(d Disconnector) Shutdownable {
+	return ShutdownFunc(d.Disconnect)
+}
+
+// FromCloser adapts an io.Closer, like a stan.Conn, to Shutdownable. Close can't be cancelled:
+// when the context is done first, the shutdown returns the context error and Close keeps
+// running in the background.
+func FromCloser(c io.Closer) Shutdownable {
+	return ShutdownFunc(func(ctx context.Context) error {
+		return runWithContext(ctx, c.Close)
+	})
+}
+
+// FromCloseFunc adapts a close function without a result, like (*banlist.Banlist).Close, to
+// Shutdownable. Like FromCloser, it stops waiting when the context is done.
+func FromCloseFunc(close func()) Shutdownable {
+	return ShutdownFunc(func(ctx context.Context) error {
+		return runWithContext(ctx, func() error {
+			close()
+			return nil
+		})
+	})
+}
+
+func runWithContext(ctx context.Context, fn func() error) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- fn()
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
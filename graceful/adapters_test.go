@@ -0,0 +1,52 @@
+package graceful
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type closer struct {
+	delay time.Duration
+	err   error
+}
+
+func (c closer) Close() error {
+	time.Sleep(c.delay)
+	return c.err
+}
+
+type disconnector struct {
+	ctx context.Context
+}
+
+func (d *disconnector) Disconnect(ctx context.Context) error {
+	d.ctx = ctx
+	return nil
+}
+
+func TestFromCloser(t *testing.T) {
+	assert.NoError(t, FromCloser(closer{}).Shutdown(context.Background()))
+	assert.EqualError(t, FromCloser(closer{err: errors.New("boom")}).Shutdown(context.Background()), "boom")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, FromCloser(closer{delay: time.Second}).Shutdown(ctx))
+}
+
+func TestFromCloseFunc(t *testing.T) {
+	called := false
+	require.NoError(t, FromCloseFunc(func() { called = true }).Shutdown(context.Background()))
+	assert.True(t, called)
+}
+
+func TestFromDisconnector(t *testing.T) {
+	ctx := context.WithValue(context.Background(), struct{}{}, "value")
+	d := new(disconnector)
+	require.NoError(t, FromDisconnector(d).Shutdown(ctx))
+	assert.Equal(t, ctx, d.ctx)
+}
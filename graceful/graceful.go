@@ -2,85 +2,230 @@ package graceful
 
 import (
 	"context"
+	"io"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
-	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// exit is replaced in tests
+var exit = os.Exit
+
+// DefaultProgressInterval is how often the targets that are still shutting down are logged
+// when Closer.ProgressInterval isn't set.
+const DefaultProgressInterval = 5 * time.Second
+
 // Shutdownable is a target that can be closed gracefully
 type Shutdownable interface {
 	Shutdown(context.Context) error
 }
 
+// Phase orders the shutdown of targets. All targets of a phase are shut down concurrently, and
+// a phase only starts once every target of the phases before it is done.
+type Phase int
+
+// The phases a service usually goes through. Any other value can be used to order targets in
+// between, lower phases are shut down first.
+const (
+	// PhaseStopTraffic stops accepting new work, like HTTP servers and queue subscriptions.
+	PhaseStopTraffic Phase = 100
+	// PhaseDrain waits for in-flight work to finish, like background workers.
+	PhaseDrain Phase = 200
+	// PhaseCloseClients closes the clients the work depended on, like database connections.
+	PhaseCloseClients Phase = 300
+	// PhaseFlushTelemetry flushes buffered logs, metrics and traces.
+	PhaseFlushTelemetry Phase = 400
+)
+
+// RegisterOption configures how a target is shut down.
+type RegisterOption func(*target)
+
+// InPhase sets the phase of a target. Targets registered without a phase are in
+// PhaseStopTraffic.
+func InPhase(phase Phase) RegisterOption {
+	return func(t *target) {
+		t.phase = phase
+	}
+}
+
+// DependsOn declares that a target uses the targets with the given names, so they are only
+// shut down after it is done, even when they are in the same phase.
+func DependsOn(names ...string) RegisterOption {
+	return func(t *target) {
+		t.dependsOn = append(t.dependsOn, names...)
+	}
+}
+
 type target struct {
-	name    string
-	shut    Shutdownable
-	timeout time.Duration
+	name      string
+	shut      Shutdownable
+	timeout   time.Duration
+	phase     Phase
+	dependsOn []string
 }
 
-// Closer handles shutdown of servers and connections
+// Closer handles shutdown of servers and connections. The zero value is ready to use.
 type Closer struct {
+	// Timeout bounds the whole shutdown on top of the timeout of each target. Targets that
+	// haven't started when it elapses are shut down with an expired context. Zero means no limit.
+	Timeout time.Duration
+	// ProgressInterval is how often the targets that are still shutting down are logged.
+	// Zero means DefaultProgressInterval, a negative value disables the logging.
+	ProgressInterval time.Duration
+	// StackDump receives the stacks of all goroutines when Timeout elapses, to find out where
+	// the pending targets are stuck. Nil disables the dump.
+	StackDump io.Writer
+
 	targets      []target
 	targetsMutex sync.Mutex
+	log          logrus.FieldLogger
+	// pendingTargets lists the targets still shutting down, set once the shutdown started
+	pendingTargets func() []string
 
-	done     chan struct{}
-	doneBool int32
+	initOnce     sync.Once
+	trigger      chan struct{}
+	triggerOnce  sync.Once
+	shutdownOnce sync.Once
+	done         chan struct{}
+	err          error
+	results      []TargetResult
+}
+
+func (cc *Closer) init() {
+	cc.initOnce.Do(func() {
+		cc.trigger = make(chan struct{})
+		cc.done = make(chan struct{})
+	})
 }
 
 // Register inserts a target to shutdown gracefully
-func (cc *Closer) Register(name string, shut Shutdownable, timeout time.Duration) {
-	cc.targetsMutex.Lock()
-	cc.targets = append(cc.targets, target{
+func (cc *Closer) Register(name string, shut Shutdownable, timeout time.Duration, opts ...RegisterOption) {
+	targ := target{
 		name:    name,
 		shut:    shut,
 		timeout: timeout,
-	})
+		phase:   PhaseStopTraffic,
+	}
+	for _, opt := range opts {
+		opt(&targ)
+	}
+
+	cc.targetsMutex.Lock()
+	cc.targets = append(cc.targets, targ)
 	cc.targetsMutex.Unlock()
 }
 
 // DetectShutdown asynchronously waits for a shutdown signal and then shuts down gracefully
-// Returns a function to trigger a shutdown from the outside, like cancelling a context
+// Returns a function to trigger a shutdown from the outside, like cancelling a context. The
+// function doesn't block and can be called any number of times.
+// A signal received while the shutdown is in progress exits the process right away with
+// exit code 1.
 func (cc *Closer) DetectShutdown(log logrus.FieldLogger) func() {
+	cc.init()
+	cc.targetsMutex.Lock()
+	cc.log = log
+	cc.targetsMutex.Unlock()
+
+	// subscribe before returning, so a signal that arrives right away doesn't kill the process
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 	go func() {
-		signals := make(chan os.Signal, 1)
-		signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
+		defer signal.Stop(signals)
+
 		select {
 		case sig := <-signals:
 			log.Infof("Triggering shutdown from signal %s", sig)
-		case <-cc.done:
+		case <-cc.trigger:
 			log.Infof("Shutting down...")
+		case <-cc.done:
+			return
 		}
 
-		if atomic.SwapInt32(&cc.doneBool, 1) != 1 {
-			wg := sync.WaitGroup{}
-			cc.targetsMutex.Lock()
-			for _, targ := range cc.targets {
-				wg.Add(1)
-				go func(targ target, log logrus.FieldLogger) {
-					defer wg.Done()
-
-					ctx, cancel := context.WithTimeout(context.Background(), targ.timeout)
-					defer cancel()
-
-					if err := targ.shut.Shutdown(ctx); err != nil {
-						log.WithError(err).Error("Graceful shutdown failed")
-					} else {
-						log.Info("Shutdown finished")
-					}
-				}(targ, log.WithField("target", targ.name))
-			}
-			cc.targetsMutex.Unlock()
-			wg.Wait()
-			os.Exit(0)
+		go cc.Shutdown(context.Background())
+		select {
+		case sig := <-signals:
+			log.WithField("pending", strings.Join(cc.pending(), ", ")).Errorf("Forcing exit from second signal %s", sig)
+			exit(1)
+		case <-cc.done:
 		}
 	}()
 
-	return func() {
-		cc.done <- struct{}{}
+	return cc.Trigger
+}
+
+// Trigger starts the shutdown when DetectShutdown is waiting for a signal. It doesn't block and
+// can be called any number of times.
+func (cc *Closer) Trigger() {
+	cc.init()
+	cc.triggerOnce.Do(func() {
+		close(cc.trigger)
+	})
+}
+
+// Shutdown shuts down all registered targets and returns a *ShutdownError listing the targets
+// that failed. ctx bounds the whole shutdown, like Timeout. Only the first call shuts down,
+// later calls wait for it and return the same result.
+func (cc *Closer) Shutdown(ctx context.Context) error {
+	cc.init()
+	cc.shutdownOnce.Do(func() {
+		cc.targetsMutex.Lock()
+		log := cc.log
+		cc.targetsMutex.Unlock()
+		if log == nil {
+			log = logrus.StandardLogger()
+		}
+
+		cc.results, cc.err = cc.shutdown(ctx, log)
+		close(cc.done)
+	})
+	return cc.Err()
+}
+
+// Done returns a channel that is closed when the shutdown is complete.
+func (cc *Closer) Done() <-chan struct{} {
+	cc.init()
+	return cc.done
+}
+
+// Err returns the result of the shutdown once Done is closed, and nil before.
+func (cc *Closer) Err() error {
+	select {
+	case <-cc.Done():
+		return cc.err
+	default:
+		return nil
+	}
+}
+
+// Report returns the result of every target once Done is closed, and nil before.
+func (cc *Closer) Report() []TargetResult {
+	select {
+	case <-cc.Done():
+		return append([]TargetResult(nil), cc.results...)
+	default:
+		return nil
+	}
+}
+
+// Wait blocks until the shutdown is complete and exits the process. The exit code is 1 when
+// any target failed to shut down, and 0 otherwise.
+func (cc *Closer) Wait() {
+	<-cc.Done()
+	if cc.Err() != nil {
+		exit(1)
+		return
 	}
+	exit(0)
+}
+
+// Run waits for a shutdown signal, shuts down and exits the process, see DetectShutdown and
+// Wait.
+func (cc *Closer) Run(log logrus.FieldLogger) {
+	cc.DetectShutdown(log)
+	cc.Wait()
 }
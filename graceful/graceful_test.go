@@ -0,0 +1,118 @@
+package graceful
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stubExit(t *testing.T) <-chan int {
+	old := exit
+	codes := make(chan int, 1)
+	exit = func(code int) {
+		codes <- code
+	}
+	t.Cleanup(func() {
+		exit = old
+	})
+	return codes
+}
+
+func waitDone(t *testing.T, cc *Closer) {
+	select {
+	case <-cc.Done():
+	case <-time.After(time.Second):
+		t.Fatal("shutdown didn't finish")
+	}
+}
+
+func TestShutdownAggregatesErrors(t *testing.T) {
+	cc := new(Closer)
+	cc.Register("ok", ShutdownFunc(func(context.Context) error { return nil }), time.Second)
+	cc.Register("db", ShutdownFunc(func(context.Context) error { return errors.New("connection reset") }), time.Second)
+	cc.Register("queue", ShutdownFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), time.Millisecond)
+
+	err := cc.Shutdown(context.Background())
+	require.IsType(t, &ShutdownError{}, err)
+	serr := err.(*ShutdownError)
+	require.Len(t, serr.Errors, 2)
+	assert.Equal(t, "db", serr.Errors[0].Target)
+	assert.EqualError(t, serr.Errors[0], "db: connection reset")
+	assert.True(t, errors.Is(serr.Errors[1], context.DeadlineExceeded))
+	assert.EqualError(t, err, "2 of the targets failed to shut down: db: connection reset; queue: context deadline exceeded")
+
+	waitDone(t, cc)
+	assert.Equal(t, err, cc.Err())
+}
+
+func TestShutdownOnce(t *testing.T) {
+	calls := 0
+	cc := new(Closer)
+	cc.Register("counter", ShutdownFunc(func(context.Context) error {
+		calls++
+		return nil
+	}), time.Second)
+
+	assert.Nil(t, cc.Err())
+	require.NoError(t, cc.Shutdown(context.Background()))
+	require.NoError(t, cc.Shutdown(context.Background()))
+	assert.Equal(t, 1, calls)
+}
+
+func TestShutdownContextDeadline(t *testing.T) {
+	cc := new(Closer)
+	cc.Register("stuck", ShutdownFunc(func(context.Context) error {
+		select {}
+	}), time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := cc.Shutdown(ctx)
+	require.IsType(t, &ShutdownError{}, err)
+	assert.Equal(t, "stuck", err.(*ShutdownError).Errors[0].Target)
+}
+
+func TestTriggerIsIdempotent(t *testing.T) {
+	cc := new(Closer)
+	// triggering before anything is running must not block
+	cc.Trigger()
+
+	trigger := cc.DetectShutdown(logrus.New())
+	trigger()
+	trigger()
+	waitDone(t, cc)
+	assert.NoError(t, cc.Err())
+	trigger()
+}
+
+func TestDetectShutdownSignal(t *testing.T) {
+	codes := stubExit(t)
+	cc := new(Closer)
+	cc.Register("failing", ShutdownFunc(func(context.Context) error { return errors.New("boom") }), time.Second)
+	cc.DetectShutdown(logrus.New())
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+	waitDone(t, cc)
+	assert.Error(t, cc.Err())
+
+	cc.Wait()
+	assert.Equal(t, 1, <-codes)
+}
+
+func TestWaitExitCode(t *testing.T) {
+	codes := stubExit(t)
+	cc := new(Closer)
+	cc.Register("ok", ShutdownFunc(func(context.Context) error { return nil }), time.Second)
+
+	go cc.Shutdown(context.Background())
+	cc.Wait()
+	assert.Equal(t, 0, <-codes)
+}
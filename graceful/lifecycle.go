@@ -0,0 +1,213 @@
+package graceful
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Component is a long-lived part of a service, like a server or a queue consumer.
+type Component interface {
+	// Start runs the component until it is shut down or ctx is cancelled. It should return
+	// nil when it stops because of either, and an error when it fails.
+	Start(ctx context.Context) error
+	Shutdownable
+}
+
+// Readier is implemented by components that take a while to become ready. Group waits for
+// Ready to return before starting the next component.
+type Readier interface {
+	Ready(ctx context.Context) error
+}
+
+type component struct {
+	name  string
+	comp  Component
+	phase Phase
+}
+
+// Group starts components in order and shuts them down together with the other targets of
+// its Closer. When any component fails, all of them are shut down.
+type Group struct {
+	// StartTimeout bounds how long a component may take to become ready. Zero means no limit.
+	StartTimeout time.Duration
+
+	closer     *Closer
+	components []component
+}
+
+// NewGroup creates a group that shuts down through cc. When cc is nil a new Closer is used.
+func NewGroup(cc *Closer) *Group {
+	if cc == nil {
+		cc = new(Closer)
+	}
+	return &Group{closer: cc}
+}
+
+// Closer returns the Closer the group shuts down with, to register other targets.
+func (g *Group) Closer() *Closer {
+	return g.closer
+}
+
+// Add appends a component to the startup order and registers it with the Closer. Components
+// in the same phase are shut down in the reverse order they were added, so a component can
+// rely on the ones added before it.
+func (g *Group) Add(name string, c Component, timeout time.Duration, opts ...RegisterOption) {
+	probe := target{phase: PhaseStopTraffic}
+	for _, opt := range opts {
+		opt(&probe)
+	}
+	for i := len(g.components) - 1; i >= 0; i-- {
+		if g.components[i].phase == probe.phase {
+			opts = append(opts, DependsOn(g.components[i].name))
+			break
+		}
+	}
+
+	g.components = append(g.components, component{name: name, comp: c, phase: probe.phase})
+	g.closer.Register(name, c, timeout, opts...)
+}
+
+type componentExit struct {
+	name string
+	err  error
+}
+
+// Run starts the components in order and blocks until ctx is cancelled, a shutdown signal is
+// received, the Closer is triggered or a component fails. It then shuts down all targets of the
+// Closer. The context passed to Start is cancelled before the shutdown begins. Every component
+// is shut down, including the ones that weren't started because an earlier one failed, so
+// Shutdown must handle a component that isn't running. The returned error is the failure of the
+// component that caused the shutdown, or the error of the shutdown itself. A service's main
+// usually exits with a non-zero code when Run returns an error.
+func (g *Group) Run(ctx context.Context, log logrus.FieldLogger) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g.closer.DetectShutdown(log)
+
+	exits := make(chan componentExit, len(g.components))
+	running := 0
+	var failure error
+	exited := func(e componentExit) {
+		running--
+		if e.err != nil && failure == nil {
+			failure = fmt.Errorf("component %s failed: %w", e.name, e.err)
+		}
+	}
+
+start:
+	for _, c := range g.components {
+		log.WithField("component", c.name).Info("Starting component")
+		running++
+		go func(c component) {
+			exits <- componentExit{name: c.name, err: c.comp.Start(runCtx)}
+		}(c)
+
+		r, ok := c.comp.(Readier)
+		if !ok {
+			continue
+		}
+		readyCtx := runCtx
+		if g.StartTimeout > 0 {
+			var readyCancel context.CancelFunc
+			readyCtx, readyCancel = context.WithTimeout(runCtx, g.StartTimeout)
+			defer readyCancel()
+		}
+		ready := make(chan error, 1)
+		go func() {
+			ready <- r.Ready(readyCtx)
+		}()
+
+		for waiting := true; waiting; {
+			select {
+			case err := <-ready:
+				if err != nil && failure == nil {
+					failure = fmt.Errorf("component %s didn't become ready: %w", c.name, err)
+				}
+				waiting = false
+			case e := <-exits:
+				exited(e)
+				// a component that finished without error counts as ready
+				waiting = e.name != c.name && failure == nil
+			case <-ctx.Done():
+				break start
+			case <-g.closer.Done():
+				break start
+			}
+			if failure != nil {
+				break start
+			}
+		}
+	}
+
+	if failure == nil {
+		log.Info("All components started")
+	}
+wait:
+	for failure == nil && running > 0 {
+		select {
+		case e := <-exits:
+			exited(e)
+		case <-ctx.Done():
+			log.Info("Shutting down because the context is done")
+			break wait
+		case <-g.closer.Done():
+			break wait
+		}
+	}
+
+	if failure != nil {
+		log.WithError(failure).Error("Shutting down after a component failed")
+	}
+	// stop the components that watch ctx before waiting for their Shutdown
+	cancel()
+	err := g.closer.Shutdown(context.Background())
+	if failure != nil {
+		return failure
+	}
+	return err
+}
+
+// HTTPServer adapts an http.Server to a Component. It is ready once it listens on srv.Addr.
+func HTTPServer(srv *http.Server) Component {
+	return &httpServer{srv: srv, listening: make(chan struct{})}
+}
+
+type httpServer struct {
+	srv       *http.Server
+	listening chan struct{}
+}
+
+func (s *httpServer) Start(ctx context.Context) error {
+	addr := s.srv.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	close(s.listening)
+
+	if err := s.srv.Serve(ln); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *httpServer) Ready(ctx context.Context) error {
+	select {
+	case <-s.listening:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *httpServer) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
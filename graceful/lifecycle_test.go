@@ -0,0 +1,217 @@
+package graceful
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type events struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (e *events) add(event string) {
+	e.mu.Lock()
+	e.log = append(e.log, event)
+	e.mu.Unlock()
+}
+
+func (e *events) all() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.log...)
+}
+
+type testComponent struct {
+	name     string
+	events   *events
+	readyIn  time.Duration
+	failWith error
+	ready    chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newTestComponent(name string, ev *events) *testComponent {
+	return &testComponent{name: name, events: ev, ready: make(chan struct{}), stop: make(chan struct{})}
+}
+
+func (c *testComponent) Start(ctx context.Context) error {
+	c.events.add("start " + c.name)
+	time.Sleep(c.readyIn)
+	if c.failWith != nil {
+		return c.failWith
+	}
+	close(c.ready)
+	select {
+	case <-c.stop:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+func (c *testComponent) Ready(ctx context.Context) error {
+	select {
+	case <-c.ready:
+		c.events.add("ready " + c.name)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *testComponent) Shutdown(context.Context) error {
+	c.events.add("shutdown " + c.name)
+	c.stopOnce.Do(func() { close(c.stop) })
+	return nil
+}
+
+func TestGroupOrderedStartup(t *testing.T) {
+	ev := new(events)
+	g := NewGroup(nil)
+	db := newTestComponent("db", ev)
+	db.readyIn = 20 * time.Millisecond
+	g.Add("db", db, time.Second)
+	g.Add("api", newTestComponent("api", ev), time.Second)
+	g.Closer().Register("telemetry", ShutdownFunc(func(context.Context) error {
+		ev.add("shutdown telemetry")
+		return nil
+	}), time.Second, InPhase(PhaseFlushTelemetry))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- g.Run(ctx, logrus.New())
+	}()
+
+	require.Eventually(t, func() bool { return len(ev.all()) == 4 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"start db", "ready db", "start api", "ready api"}, ev.all())
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"shutdown api", "shutdown db", "shutdown telemetry"}, ev.all()[4:])
+}
+
+func TestGroupComponentFailure(t *testing.T) {
+	ev := new(events)
+	g := NewGroup(nil)
+	g.Add("db", newTestComponent("db", ev), time.Second)
+	broken := newTestComponent("consumer", ev)
+	broken.failWith = errors.New("connection refused")
+	g.Add("consumer", broken, time.Second)
+	g.Add("api", newTestComponent("api", ev), time.Second)
+
+	err := g.Run(context.Background(), logrus.New())
+	assert.EqualError(t, err, "component consumer failed: connection refused")
+	assert.NotContains(t, ev.all(), "start api")
+	assert.Contains(t, ev.all(), "shutdown db")
+	// components that never started are shut down too
+	assert.Contains(t, ev.all(), "shutdown api")
+}
+
+func TestGroupFailureCancelsComponents(t *testing.T) {
+	g := NewGroup(nil)
+	// a consumer that stops when its context is cancelled and waits for that on shutdown
+	stopped := make(chan struct{})
+	g.Add("consumer", &struct {
+		ShutdownFunc
+		startFunc
+	}{
+		ShutdownFunc(func(ctx context.Context) error {
+			select {
+			case <-stopped:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}),
+		startFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			close(stopped)
+			return nil
+		}),
+	}, time.Second)
+	g.Add("worker", &struct {
+		ShutdownFunc
+		startFunc
+	}{
+		ShutdownFunc(func(context.Context) error { return nil }),
+		startFunc(func(context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			return errors.New("lost lease")
+		}),
+	}, time.Second)
+
+	start := time.Now()
+	err := g.Run(context.Background(), logrus.New())
+	assert.EqualError(t, err, "component worker failed: lost lease")
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+	for _, res := range g.Closer().Report() {
+		assert.NoError(t, res.Err, res.Name)
+	}
+}
+
+func TestGroupFailureAfterStartup(t *testing.T) {
+	g := NewGroup(nil)
+	g.Add("api", newTestComponent("api", new(events)), time.Second)
+	g.Add("worker", &struct {
+		ShutdownFunc
+		startFunc
+	}{
+		ShutdownFunc(func(context.Context) error { return nil }),
+		startFunc(func(context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			return errors.New("lost lease")
+		}),
+	}, time.Second)
+
+	err := g.Run(context.Background(), logrus.New())
+	assert.EqualError(t, err, "component worker failed: lost lease")
+}
+
+type startFunc func(context.Context) error
+
+func (f startFunc) Start(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestGroupReadinessTimeout(t *testing.T) {
+	g := NewGroup(nil)
+	g.StartTimeout = 10 * time.Millisecond
+	slow := newTestComponent("slow", new(events))
+	slow.readyIn = time.Second
+	g.Add("slow", slow, time.Second)
+
+	err := g.Run(context.Background(), logrus.New())
+	assert.EqualError(t, err, "component slow didn't become ready: context deadline exceeded")
+}
+
+func TestGroupTriggeredShutdown(t *testing.T) {
+	cc := new(Closer)
+	g := NewGroup(cc)
+	srv := HTTPServer(&http.Server{Addr: "127.0.0.1:0"})
+	g.Add("http", srv, time.Second)
+
+	done := make(chan error)
+	go func() {
+		done <- g.Run(context.Background(), logrus.New())
+	}()
+	require.NoError(t, srv.(Readier).Ready(context.Background()))
+
+	cc.Trigger()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("group didn't stop")
+	}
+	assert.Len(t, cc.Report(), 1)
+}
@@ -0,0 +1,121 @@
+package graceful
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	cc := &Closer{Timeout: 50 * time.Millisecond}
+	cc.Register("fast", ShutdownFunc(func(context.Context) error { return nil }), time.Second)
+	cc.Register("failing", ShutdownFunc(func(context.Context) error { return errors.New("boom") }), time.Second)
+	cc.Register("slow", ShutdownFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), 10*time.Millisecond)
+	cc.Register("stuck", ShutdownFunc(func(context.Context) error {
+		select {}
+	}), time.Hour, InPhase(PhaseDrain))
+	called := make(chan struct{})
+	cc.Register("never", ShutdownFunc(func(context.Context) error {
+		close(called)
+		return nil
+	}), time.Second, InPhase(PhaseCloseClients))
+
+	assert.Nil(t, cc.Report())
+	assert.Error(t, cc.Shutdown(context.Background()))
+
+	report := cc.Report()
+	require.Len(t, report, 5)
+	byName := make(map[string]TargetResult)
+	for _, r := range report {
+		byName[r.Name] = r
+	}
+
+	assert.True(t, byName["fast"].Started)
+	assert.NoError(t, byName["fast"].Err)
+	assert.False(t, byName["fast"].TimedOut)
+
+	assert.EqualError(t, byName["failing"].Err, "boom")
+	assert.False(t, byName["failing"].TimedOut)
+
+	assert.True(t, byName["slow"].TimedOut)
+	assert.True(t, byName["slow"].Duration >= 10*time.Millisecond)
+
+	assert.Equal(t, PhaseDrain, byName["stuck"].Phase)
+	assert.True(t, byName["stuck"].Started)
+	assert.True(t, byName["stuck"].TimedOut)
+	assert.True(t, byName["stuck"].Duration > 0)
+
+	// the deadline elapsed while waiting for the stuck target
+	assert.Equal(t, PhaseCloseClients, byName["never"].Phase)
+	assert.False(t, byName["never"].Started)
+	assert.True(t, byName["never"].TimedOut)
+	assert.Equal(t, context.DeadlineExceeded, byName["never"].Err)
+	assert.Zero(t, byName["never"].Duration)
+	select {
+	case <-called:
+		t.Error("Shutdown was called after the deadline")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestProgressLoggingAndStackDump(t *testing.T) {
+	log, hook := test.NewNullLogger()
+	dump := new(bytes.Buffer)
+	cc := &Closer{Timeout: 60 * time.Millisecond, ProgressInterval: 10 * time.Millisecond, StackDump: dump}
+	cc.Register("stuck", ShutdownFunc(func(context.Context) error {
+		select {}
+	}), time.Hour)
+	cc.Register("done", ShutdownFunc(func(context.Context) error { return nil }), time.Hour)
+	cc.DetectShutdown(log)
+
+	assert.Error(t, cc.Shutdown(context.Background()))
+
+	var progress, deadline int
+	for _, entry := range hook.AllEntries() {
+		switch entry.Message {
+		case "Waiting for targets to shut down":
+			progress++
+			assert.Equal(t, "stuck", entry.Data["pending"])
+		case "Shutdown deadline exceeded":
+			deadline++
+			assert.Equal(t, logrus.ErrorLevel, entry.Level)
+		}
+	}
+	assert.True(t, progress > 0)
+	assert.Equal(t, 1, deadline)
+	assert.Contains(t, dump.String(), "goroutine")
+	assert.Contains(t, dump.String(), "TestProgressLoggingAndStackDump")
+}
+
+func TestSecondSignalForcesExit(t *testing.T) {
+	codes := stubExit(t)
+
+	started := make(chan struct{})
+	cc := new(Closer)
+	cc.Register("stuck", ShutdownFunc(func(context.Context) error {
+		close(started)
+		select {}
+	}), time.Hour)
+	cc.DetectShutdown(logrus.New())
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGINT))
+	<-started
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGINT))
+
+	select {
+	case code := <-codes:
+		assert.Equal(t, 1, code)
+	case <-time.After(time.Second):
+		t.Fatal("second signal didn't force an exit")
+	}
+}
@@ -0,0 +1,85 @@
+// Package register registers the clients netlify-commons creates with a graceful.Closer, in the
+// phase they usually belong to. It is kept apart from graceful so that graceful itself doesn't
+// depend on the client libraries.
+package register
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/stan.go"
+	"github.com/netlify/netlify-commons/graceful"
+	"github.com/netlify/netlify-commons/http/banlist"
+	"github.com/netlify/netlify-commons/instrument"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// The functions below prepend the default phase to the options, so passing graceful.InPhase
+// overrides it.
+
+// Mongo registers a mongo client, disconnected in PhaseCloseClients
+func Mongo(cc *graceful.Closer, name string, client *mongo.Client, timeout time.Duration, opts ...graceful.RegisterOption) {
+	cc.Register(name, graceful.FromDisconnector(client), timeout, inPhase(graceful.PhaseCloseClients, opts)...)
+}
+
+// NATS registers a NATS connection, drained in PhaseCloseClients so that streaming connections
+// using it are closed first
+func NATS(cc *graceful.Closer, name string, nc *nats.Conn, timeout time.Duration, opts ...graceful.RegisterOption) {
+	cc.Register(name, FromNATSDrain(nc), timeout, inPhase(graceful.PhaseCloseClients, opts)...)
+}
+
+// Stan registers a NATS streaming connection, closed in PhaseDrain
+func Stan(cc *graceful.Closer, name string, sc stan.Conn, timeout time.Duration, opts ...graceful.RegisterOption) {
+	cc.Register(name, graceful.FromCloser(sc), timeout, inPhase(graceful.PhaseDrain, opts)...)
+}
+
+// Segment registers a segment client, closed in PhaseFlushTelemetry to send the buffered
+// events. Clients that can't be closed, like the mock client, are not registered.
+func Segment(cc *graceful.Closer, name string, client instrument.Client, timeout time.Duration, opts ...graceful.RegisterOption) {
+	closer, ok := client.(io.Closer)
+	if !ok {
+		return
+	}
+	cc.Register(name, graceful.FromCloser(closer), timeout, inPhase(graceful.PhaseFlushTelemetry, opts)...)
+}
+
+// Banlist registers a banlist, closed in PhaseCloseClients
+func Banlist(cc *graceful.Closer, name string, b *banlist.Banlist, timeout time.Duration, opts ...graceful.RegisterOption) {
+	cc.Register(name, graceful.FromCloseFunc(b.Close), timeout, inPhase(graceful.PhaseCloseClients, opts)...)
+}
+
+func inPhase(phase graceful.Phase, opts []graceful.RegisterOption) []graceful.RegisterOption {
+	return append([]graceful.RegisterOption{graceful.InPhase(phase)}, opts...)
+}
+
+// drainPollInterval is how often FromNATSDrain checks whether draining finished
+const drainPollInterval = 10 * time.Millisecond
+
+// FromNATSDrain adapts a NATS connection to graceful.Shutdownable. It drains the connection, so
+// the messages of its subscriptions that are already received are processed and pending
+// publishes are flushed, and waits for the connection to close. When the context is done first,
+// the connection is closed right away.
+func FromNATSDrain(nc *nats.Conn) graceful.Shutdownable {
+	return graceful.ShutdownFunc(func(ctx context.Context) error {
+		if err := nc.Drain(); err != nil {
+			if err == nats.ErrConnectionClosed {
+				return nil
+			}
+			return err
+		}
+
+		ticker := time.NewTicker(drainPollInterval)
+		defer ticker.Stop()
+		for !nc.IsClosed() {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				nc.Close()
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+}
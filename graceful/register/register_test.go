@@ -0,0 +1,150 @@
+package register
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	stanserver "github.com/nats-io/nats-streaming-server/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/stan.go"
+	"github.com/netlify/netlify-commons/graceful"
+	"github.com/netlify/netlify-commons/http/banlist"
+	"github.com/netlify/netlify-commons/instrument"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func runNATS(t *testing.T) *natsserver.Server {
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1
+	s := natstest.RunServer(&opts)
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func natsURL(s *natsserver.Server) string {
+	return "nats://" + s.Addr().String()
+}
+
+func connectNATS(t *testing.T, s *natsserver.Server) *nats.Conn {
+	nc, err := nats.Connect(natsURL(s))
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+	return nc
+}
+
+func TestFromNATSDrain(t *testing.T) {
+	s := runNATS(t)
+
+	t.Run("drain completes", func(t *testing.T) {
+		nc := connectNATS(t, s)
+		var handled int32
+		_, err := nc.Subscribe("jobs", func(*nats.Msg) {
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&handled, 1)
+		})
+		require.NoError(t, err)
+		require.NoError(t, nc.Publish("jobs", []byte("1")))
+		require.NoError(t, nc.Publish("jobs", []byte("2")))
+		require.NoError(t, nc.Flush())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, FromNATSDrain(nc).Shutdown(ctx))
+		assert.True(t, nc.IsClosed())
+		assert.EqualValues(t, 2, atomic.LoadInt32(&handled))
+	})
+
+	t.Run("context done first", func(t *testing.T) {
+		nc := connectNATS(t, s)
+		received := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		_, err := nc.Subscribe("jobs", func(*nats.Msg) {
+			close(received)
+			<-release
+		})
+		require.NoError(t, err)
+		require.NoError(t, nc.Publish("jobs", nil))
+		<-received
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, FromNATSDrain(nc).Shutdown(ctx))
+		assert.True(t, nc.IsClosed())
+	})
+
+	t.Run("already closed", func(t *testing.T) {
+		nc := connectNATS(t, s)
+		nc.Close()
+		assert.NoError(t, FromNATSDrain(nc).Shutdown(context.Background()))
+	})
+}
+
+// closingClient is an instrument.Client that can be closed, like the segment client
+type closingClient struct {
+	instrument.MockClient
+	closed bool
+}
+
+func (c *closingClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banlist.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"domains": [], "urls": []}`), 0644))
+
+	natsd := runNATS(t)
+	nc := connectNATS(t, natsd)
+
+	stanOpts := stanserver.GetDefaultOptions()
+	stanOpts.ID = "test-cluster"
+	stanOpts.NATSServerURL = natsURL(natsd)
+	stand, err := stanserver.RunServerWithOpts(stanOpts, nil)
+	require.NoError(t, err)
+	defer stand.Shutdown()
+	sc, err := stan.Connect("test-cluster", "register-test", stan.NatsURL(natsURL(natsd)))
+	require.NoError(t, err)
+
+	mc, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	require.NoError(t, err)
+
+	segment := new(closingClient)
+
+	cc := new(graceful.Closer)
+	Mongo(cc, "mongo", mc, time.Second)
+	NATS(cc, "nats", nc, time.Second)
+	Stan(cc, "stan", sc, time.Second)
+	Segment(cc, "segment", segment, time.Second)
+	Segment(cc, "mock", instrument.MockClient{}, time.Second)
+	Banlist(cc, "banlist", banlist.New(logrus.New(), path), time.Second)
+	Banlist(cc, "other", banlist.New(logrus.New(), path), time.Second, graceful.InPhase(graceful.PhaseDrain))
+	require.NoError(t, cc.Shutdown(context.Background()))
+
+	phases := map[string]graceful.Phase{}
+	for _, res := range cc.Report() {
+		phases[res.Name] = res.Phase
+	}
+	assert.Equal(t, map[string]graceful.Phase{
+		"mongo":   graceful.PhaseCloseClients,
+		"nats":    graceful.PhaseCloseClients,
+		"stan":    graceful.PhaseDrain,
+		"segment": graceful.PhaseFlushTelemetry,
+		"banlist": graceful.PhaseCloseClients,
+		"other":   graceful.PhaseDrain,
+	}, phases)
+	assert.True(t, segment.closed)
+	assert.True(t, nc.IsClosed())
+	assert.Nil(t, sc.NatsConn())
+}
@@ -0,0 +1,286 @@
+package graceful
+
+import (
+	"context"
+	"fmt"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TargetError is the error of a target that failed to shut down.
+type TargetError struct {
+	Target string
+	Err    error
+}
+
+func (e *TargetError) Error() string {
+	return e.Target + ": " + e.Err.Error()
+}
+
+func (e *TargetError) Unwrap() error {
+	return e.Err
+}
+
+// ShutdownError lists the targets that failed to shut down.
+type ShutdownError struct {
+	Errors []*TargetError
+}
+
+func (e *ShutdownError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d of the targets failed to shut down: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// TargetResult reports how the shutdown of a target went.
+type TargetResult struct {
+	Name  string
+	Phase Phase
+	// Started is false when the overall deadline elapsed before the target's turn came. Such
+	// targets are reported with the context error and their Shutdown is never called.
+	Started  bool
+	Duration time.Duration
+	Err      error
+	// TimedOut is set when the target's own timeout or the overall deadline elapsed.
+	TimedOut bool
+}
+
+// shutdown shuts down all targets, ordered by phase and dependencies. It returns when all
+// targets are done or the overall timeout elapsed, the targets that are still running then
+// are reported with the context error.
+func (cc *Closer) shutdown(ctx context.Context, log logrus.FieldLogger) ([]TargetResult, error) {
+	cc.targetsMutex.Lock()
+	targets := append([]target(nil), cc.targets...)
+	cc.targetsMutex.Unlock()
+
+	if cc.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cc.Timeout)
+		defer cancel()
+	}
+
+	prereqs := schedule(targets, log)
+	done := make([]chan struct{}, len(targets))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	var resultsMutex sync.Mutex
+	results := make([]TargetResult, len(targets))
+	started := make([]time.Time, len(targets))
+	finished := make([]bool, len(targets))
+	for i, targ := range targets {
+		results[i] = TargetResult{Name: targ.name, Phase: targ.phase}
+	}
+
+	wg := sync.WaitGroup{}
+	for i, targ := range targets {
+		wg.Add(1)
+		go func(i int, targ target, log logrus.FieldLogger) {
+			defer wg.Done()
+			defer close(done[i])
+
+			for _, p := range prereqs[i] {
+				select {
+				case <-done[p]:
+				case <-ctx.Done():
+				}
+			}
+			if ctx.Err() != nil {
+				// the deadline elapsed before the target's turn came, the final snapshot
+				// reports it as not started
+				log.Error("Shutdown deadline exceeded before the target started")
+				return
+			}
+
+			tctx, cancel := context.WithTimeout(ctx, targ.timeout)
+			defer cancel()
+
+			start := time.Now()
+			resultsMutex.Lock()
+			results[i].Started = true
+			started[i] = start
+			resultsMutex.Unlock()
+
+			err := targ.shut.Shutdown(tctx)
+
+			resultsMutex.Lock()
+			results[i].Duration = time.Since(start)
+			results[i].Err = err
+			results[i].TimedOut = err != nil && tctx.Err() != nil
+			finished[i] = true
+			resultsMutex.Unlock()
+
+			if err != nil {
+				log.WithError(err).Error("Graceful shutdown failed")
+			} else {
+				log.Info("Shutdown finished")
+			}
+		}(i, targ, log.WithField("target", targ.name))
+	}
+
+	pending := func() []string {
+		resultsMutex.Lock()
+		defer resultsMutex.Unlock()
+		var names []string
+		for i, f := range finished {
+			if !f {
+				names = append(names, targets[i].name)
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+	cc.targetsMutex.Lock()
+	cc.pendingTargets = pending
+	cc.targetsMutex.Unlock()
+
+	allDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(allDone)
+	}()
+
+	var progress <-chan time.Time
+	if interval := cc.progressInterval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		progress = ticker.C
+	}
+
+wait:
+	for {
+		select {
+		case <-allDone:
+			break wait
+		case <-progress:
+			log.WithField("pending", strings.Join(pending(), ", ")).Info("Waiting for targets to shut down")
+		case <-ctx.Done():
+			log.WithField("pending", strings.Join(pending(), ", ")).Error("Shutdown deadline exceeded")
+			if cc.StackDump != nil {
+				if err := pprof.Lookup("goroutine").WriteTo(cc.StackDump, 2); err != nil {
+					log.WithError(err).Error("Failed to dump goroutine stacks")
+				}
+			}
+			break wait
+		}
+	}
+
+	// targets that are still running keep going in the background, take a snapshot
+	resultsMutex.Lock()
+	defer resultsMutex.Unlock()
+	var failed []*TargetError
+	for i := range results {
+		r := &results[i]
+		if !finished[i] {
+			r.Err, r.TimedOut = ctx.Err(), true
+			if r.Started {
+				r.Duration = time.Since(started[i])
+			}
+		}
+		if r.Err != nil {
+			failed = append(failed, &TargetError{Target: r.Name, Err: r.Err})
+		}
+	}
+	report := append([]TargetResult(nil), results...)
+	if len(failed) > 0 {
+		return report, &ShutdownError{Errors: failed}
+	}
+	return report, nil
+}
+
+// pending returns the names of the targets that are still shutting down.
+func (cc *Closer) pending() []string {
+	cc.targetsMutex.Lock()
+	pending := cc.pendingTargets
+	cc.targetsMutex.Unlock()
+	if pending == nil {
+		return nil
+	}
+	return pending()
+}
+
+func (cc *Closer) progressInterval() time.Duration {
+	if cc.ProgressInterval == 0 {
+		return DefaultProgressInterval
+	}
+	return cc.ProgressInterval
+}
+
+// schedule returns for each target the indexes of the targets that must be done before it
+// starts: all targets of earlier phases, and the targets that depend on it. When the
+// dependencies can't be satisfied, they are ignored and only the phases are used.
+func schedule(targets []target, log logrus.FieldLogger) [][]int {
+	byName := make(map[string][]int)
+	for i, t := range targets {
+		byName[t.name] = append(byName[t.name], i)
+	}
+
+	phases := make([][]int, len(targets))
+	withDeps := make([][]int, len(targets))
+	for i, t := range targets {
+		for j, other := range targets {
+			if other.phase < t.phase {
+				phases[i] = append(phases[i], j)
+			}
+		}
+		withDeps[i] = append(withDeps[i], phases[i]...)
+	}
+	for i, t := range targets {
+		for _, name := range t.dependsOn {
+			deps, ok := byName[name]
+			if !ok {
+				log.WithField("target", t.name).Warnf("Ignoring dependency on unknown target %s", name)
+				continue
+			}
+			for _, dep := range deps {
+				withDeps[dep] = append(withDeps[dep], i)
+			}
+		}
+	}
+
+	if hasCycle(withDeps) {
+		log.Error("Shutdown dependencies contradict each other or the phases, shutting down by phase only")
+		return phases
+	}
+	return withDeps
+}
+
+func hasCycle(edges [][]int) bool {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(edges))
+	var visit func(int) bool
+	visit = func(i int) bool {
+		switch state[i] {
+		case visiting:
+			return true
+		case visited:
+			return false
+		}
+		state[i] = visiting
+		for _, j := range edges[i] {
+			if visit(j) {
+				return true
+			}
+		}
+		state[i] = visited
+		return false
+	}
+	for i := range edges {
+		if visit(i) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package graceful
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (r *recorder) target(name string, delay time.Duration) Shutdownable {
+	return ShutdownFunc(func(ctx context.Context) error {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+		r.mu.Lock()
+		r.order = append(r.order, name)
+		r.mu.Unlock()
+		return nil
+	})
+}
+
+func TestShutdownPhases(t *testing.T) {
+	rec := new(recorder)
+	cc := new(Closer)
+	cc.Register("telemetry", rec.target("telemetry", 0), time.Second, InPhase(PhaseFlushTelemetry))
+	cc.Register("mongo", rec.target("mongo", 0), time.Second, InPhase(PhaseCloseClients))
+	cc.Register("server", rec.target("server", 20*time.Millisecond), time.Second)
+	cc.Register("worker", rec.target("worker", 10*time.Millisecond), time.Second, InPhase(PhaseDrain))
+
+	cc.shutdown(context.Background(), logrus.New())
+	assert.Equal(t, []string{"server", "worker", "mongo", "telemetry"}, rec.order)
+}
+
+func TestShutdownDependsOn(t *testing.T) {
+	rec := new(recorder)
+	cc := new(Closer)
+	cc.Register("mongo", rec.target("mongo", 0), time.Second)
+	cc.Register("cache", rec.target("cache", 0), time.Second)
+	cc.Register("server", rec.target("server", 20*time.Millisecond), time.Second, DependsOn("mongo", "unknown"))
+
+	cc.shutdown(context.Background(), logrus.New())
+	assert.Equal(t, []string{"cache", "server", "mongo"}, rec.order)
+}
+
+func TestShutdownCyclicDependencies(t *testing.T) {
+	rec := new(recorder)
+	cc := new(Closer)
+	cc.Register("a", rec.target("a", 0), time.Second, DependsOn("b"))
+	cc.Register("b", rec.target("b", 0), time.Second, DependsOn("a"))
+	cc.Register("c", rec.target("c", 0), time.Second, InPhase(PhaseDrain))
+
+	done := make(chan struct{})
+	go func() {
+		cc.shutdown(context.Background(), logrus.New())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("shutdown deadlocked")
+	}
+	assert.Len(t, rec.order, 3)
+	assert.Equal(t, "c", rec.order[2])
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	rec := new(recorder)
+	cc := &Closer{Timeout: 20 * time.Millisecond}
+	cc.Register("stuck", ShutdownFunc(func(context.Context) error {
+		select {}
+	}), time.Hour)
+	cc.Register("client", rec.target("client", time.Hour), time.Hour, InPhase(PhaseCloseClients))
+
+	start := time.Now()
+	cc.shutdown(context.Background(), logrus.New())
+	assert.True(t, time.Since(start) < time.Second)
+}
@@ -9,6 +9,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -20,12 +21,18 @@ type Config struct {
 }
 
 type Banlist struct {
+	// checks and blocks are accessed atomically and must stay 64-bit aligned
+	checks int64
+	blocks int64
+
 	domainHolder atomic.Value
 	urlHolder    atomic.Value
 	mtx          sync.Mutex
 	ch           chan os.Signal
 	log          logrus.FieldLogger
 	path         string
+	stop         chan struct{}
+	stopOnce     sync.Once
 }
 
 func New(log logrus.FieldLogger, filepath string) *Banlist {
@@ -36,9 +43,9 @@ func New(log logrus.FieldLogger, filepath string) *Banlist {
 }
 
 func newBanlist(log logrus.FieldLogger, path string) *Banlist {
-	bl := &Banlist{log: log, path: path}
-	bl.domainHolder.Store(make(map[string]struct{}))
-	bl.urlHolder.Store(make(map[string]struct{}))
+	bl := &Banlist{log: log, path: path, stop: make(chan struct{})}
+	bl.domainHolder.Store(make(map[string]*entry))
+	bl.urlHolder.Store(make(map[string]*entry))
 	return bl
 }
 
@@ -76,14 +83,8 @@ func (b *Banlist) update() error {
 		return errors.Wrap(err, "error decoding banlist config")
 	}
 
-	domains := make(map[string]struct{})
-	urls := make(map[string]struct{})
-	for _, el := range c.Domains {
-		domains[strings.ToLower(el)] = struct{}{}
-	}
-	for _, el := range c.URLs {
-		urls[strings.ToLower(el)] = struct{}{}
-	}
+	domains := carryOver(b.domains(), c.Domains)
+	urls := carryOver(b.urls(), c.URLs)
 
 	b.domainHolder.Store(domains)
 	b.urlHolder.Store(urls)
@@ -92,13 +93,17 @@ func (b *Banlist) update() error {
 
 // CheckRequest will check if the domain is blocked or the path is blocked
 func (b *Banlist) CheckRequest(r *http.Request) bool {
+	atomic.AddInt64(&b.checks, 1)
+
 	domain := strings.SplitN(r.Host, ":", 2)[0]
-	if _, ok := b.domains()[strings.ToLower(domain)]; ok {
+	if e, ok := b.domains()[strings.ToLower(domain)]; ok {
+		b.block(e)
 		return true
 	}
 
 	url := domain + r.URL.Path
-	if _, ok := b.urls()[strings.ToLower(url)]; ok {
+	if e, ok := b.urls()[strings.ToLower(url)]; ok {
+		b.block(e)
 		return true
 	}
 
@@ -106,14 +111,40 @@ func (b *Banlist) CheckRequest(r *http.Request) bool {
 }
 
 func (b *Banlist) Close() {
-	signal.Stop(b.ch)
-	close(b.ch)
+	b.stopOnce.Do(func() {
+		close(b.stop)
+		if b.ch != nil {
+			signal.Stop(b.ch)
+			close(b.ch)
+		}
+	})
 }
 
-func (b *Banlist) domains() map[string]struct{} {
-	return b.domainHolder.Load().(map[string]struct{})
+func (b *Banlist) block(e *entry) {
+	atomic.AddInt64(&b.blocks, 1)
+	atomic.AddInt64(&e.hits, 1)
+	atomic.StoreInt64(&e.lastHit, time.Now().UnixNano())
 }
 
-func (b *Banlist) urls() map[string]struct{} {
-	return b.urlHolder.Load().(map[string]struct{})
+func (b *Banlist) domains() map[string]*entry {
+	return b.domainHolder.Load().(map[string]*entry)
+}
+
+func (b *Banlist) urls() map[string]*entry {
+	return b.urlHolder.Load().(map[string]*entry)
+}
+
+// carryOver builds the entry map for a new config, reusing the counters of
+// entries that were already banned so a reload doesn't reset their stats.
+func carryOver(old map[string]*entry, values []string) map[string]*entry {
+	out := make(map[string]*entry, len(values))
+	for _, el := range values {
+		key := strings.ToLower(el)
+		if e, ok := old[key]; ok {
+			out[key] = e
+		} else {
+			out[key] = new(entry)
+		}
+	}
+	return out
 }
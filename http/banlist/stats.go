@@ -0,0 +1,117 @@
+package banlist
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// entry holds the counters for a single banned domain or url. The fields are
+// only ever accessed atomically.
+type entry struct {
+	hits    int64
+	lastHit int64 // unix nanoseconds, 0 when never hit
+}
+
+// Stats is a point in time snapshot of the banlist counters
+type Stats struct {
+	// Checks is the number of requests passed to CheckRequest
+	Checks int64
+	// Blocks is the number of those requests that were banned
+	Blocks int64
+
+	Domains []EntryStats
+	URLs    []EntryStats
+}
+
+// EntryStats holds the counters for a single banned domain or url
+type EntryStats struct {
+	Entry   string
+	Hits    int64
+	LastHit time.Time // zero when the entry was never hit
+}
+
+// DefaultStatsInterval is the interval ReportStats uses when it's given none
+const DefaultStatsInterval = time.Minute
+
+// StatsReporter receives a stats snapshot on every reporting interval
+type StatsReporter func(Stats)
+
+// Stats returns a snapshot of the current counters. Entries are sorted by name.
+func (b *Banlist) Stats() Stats {
+	return Stats{
+		Checks:  atomic.LoadInt64(&b.checks),
+		Blocks:  atomic.LoadInt64(&b.blocks),
+		Domains: snapshot(b.domains()),
+		URLs:    snapshot(b.urls()),
+	}
+}
+
+// ReportStats calls report with a stats snapshot every interval until the banlist is closed.
+// When report is nil the stats are logged instead, when interval isn't positive
+// DefaultStatsInterval is used.
+func (b *Banlist) ReportStats(interval time.Duration, report StatsReporter) {
+	if report == nil {
+		report = LogStats(b.log)
+	}
+	if interval <= 0 {
+		interval = DefaultStatsInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				report(b.Stats())
+			case <-b.stop:
+				return
+			}
+		}
+	}()
+}
+
+// LogStats returns a StatsReporter that logs the totals and every entry that has been hit
+func LogStats(log logrus.FieldLogger) StatsReporter {
+	return func(s Stats) {
+		log.WithFields(logrus.Fields{
+			"checks": s.Checks,
+			"blocks": s.Blocks,
+		}).Info("banlist stats")
+
+		for _, list := range []struct {
+			kind    string
+			entries []EntryStats
+		}{{"domain", s.Domains}, {"url", s.URLs}} {
+			for _, e := range list.entries {
+				if e.Hits == 0 {
+					continue
+				}
+				log.WithFields(logrus.Fields{
+					list.kind:  e.Entry,
+					"hits":     e.Hits,
+					"last_hit": e.LastHit,
+				}).Debug("banlist entry stats")
+			}
+		}
+	}
+}
+
+func snapshot(entries map[string]*entry) []EntryStats {
+	out := make([]EntryStats, 0, len(entries))
+	for name, e := range entries {
+		es := EntryStats{
+			Entry: name,
+			Hits:  atomic.LoadInt64(&e.hits),
+		}
+		if last := atomic.LoadInt64(&e.lastHit); last != 0 {
+			es.LastHit = time.Unix(0, last)
+		}
+		out = append(out, es)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Entry < out[j].Entry })
+	return out
+}
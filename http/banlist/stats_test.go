@@ -0,0 +1,107 @@
+package banlist
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBanlistStats(t *testing.T) {
+	bl := testList(t, &Config{
+		URLs:    []string{"villians.com/the/joker"},
+		Domains: []string{"sick.com", "unused.com"},
+	})
+
+	for _, u := range []string{
+		"http://heros.com",
+		"http://sick.com",
+		"http://SICK.com/path",
+		"http://villians.com/the/joker",
+	} {
+		bl.CheckRequest(httptest.NewRequest(http.MethodGet, u, nil))
+	}
+
+	stats := bl.Stats()
+	assert.EqualValues(t, 4, stats.Checks)
+	assert.EqualValues(t, 3, stats.Blocks)
+
+	require.Len(t, stats.Domains, 2)
+	assert.Equal(t, "sick.com", stats.Domains[0].Entry)
+	assert.EqualValues(t, 2, stats.Domains[0].Hits)
+	assert.False(t, stats.Domains[0].LastHit.IsZero())
+	assert.Equal(t, "unused.com", stats.Domains[1].Entry)
+	assert.EqualValues(t, 0, stats.Domains[1].Hits)
+	assert.True(t, stats.Domains[1].LastHit.IsZero())
+
+	require.Len(t, stats.URLs, 1)
+	assert.EqualValues(t, 1, stats.URLs[0].Hits)
+}
+
+func TestBanlistStatsSurviveReload(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	write := func(c *Config) {
+		data, err := json.Marshal(c)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(f.Name(), data, 0644))
+	}
+
+	write(&Config{Domains: []string{"sick.com", "gone.com"}})
+	bl := newBanlist(tl(t), f.Name())
+	require.NoError(t, bl.update())
+
+	bl.CheckRequest(httptest.NewRequest(http.MethodGet, "http://sick.com", nil))
+	bl.CheckRequest(httptest.NewRequest(http.MethodGet, "http://gone.com", nil))
+
+	write(&Config{Domains: []string{"sick.com", "new.com"}})
+	require.NoError(t, bl.update())
+
+	stats := bl.Stats()
+	require.Len(t, stats.Domains, 2)
+	assert.Equal(t, "new.com", stats.Domains[0].Entry)
+	assert.EqualValues(t, 0, stats.Domains[0].Hits)
+	assert.Equal(t, "sick.com", stats.Domains[1].Entry)
+	assert.EqualValues(t, 1, stats.Domains[1].Hits)
+}
+
+func TestBanlistReportStats(t *testing.T) {
+	bl := testList(t, &Config{Domains: []string{"sick.com"}})
+	bl.CheckRequest(httptest.NewRequest(http.MethodGet, "http://sick.com", nil))
+
+	reports := make(chan Stats, 1)
+	bl.ReportStats(time.Millisecond, func(s Stats) {
+		select {
+		case reports <- s:
+		default:
+		}
+	})
+	defer bl.Close()
+
+	select {
+	case s := <-reports:
+		assert.EqualValues(t, 1, s.Blocks)
+	case <-time.After(time.Second):
+		t.Fatal("no stats were reported")
+	}
+}
+
+func TestBanlistReportStatsDefaultInterval(t *testing.T) {
+	bl := testList(t, &Config{Domains: []string{"sick.com"}})
+	for _, interval := range []time.Duration{0, -time.Second} {
+		bl.ReportStats(interval, func(Stats) {
+			t.Error("stats were reported before the default interval")
+		})
+	}
+	// time.NewTicker panics on a non-positive interval, give the goroutines time to start
+	time.Sleep(10 * time.Millisecond)
+	bl.Close()
+}
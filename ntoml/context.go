@@ -0,0 +1,152 @@
+package ntoml
+
+// The deploy contexts Netlify builds in. Any other context name refers to a branch.
+const (
+	ContextProduction    = "production"
+	ContextDeployPreview = "deploy-preview"
+	ContextBranchDeploy  = "branch-deploy"
+)
+
+// ResolveBuild returns the build settings for a deploy of branch in the given deploy context.
+// Settings from the branch-named context take precedence over the deploy context, which takes
+// precedence over [build]. Environment variables are merged key by key.
+func (n *NetlifyToml) ResolveBuild(context, branch string) BuildConfig {
+	var out BuildConfig
+	if n.Build != nil {
+		mergeBuild(&out, n.Build)
+	}
+	for _, dc := range n.contexts(context, branch) {
+		mergeBuild(&out, &dc.BuildConfig)
+	}
+	return out
+}
+
+// ResolvePlugins returns the plugins for a deploy of branch in the given deploy context, using
+// the same precedence as ResolveBuild. Plugins are merged by package: a context can pin another
+// version or override inputs of a plugin declared in [[plugins]], and add new plugins.
+func (n *NetlifyToml) ResolvePlugins(context, branch string) []Plugin {
+	out := mergePlugins(nil, n.Plugins)
+	for _, dc := range n.contexts(context, branch) {
+		out = mergePlugins(out, dc.Plugins)
+	}
+	return out
+}
+
+// ResolveRedirects returns the redirects for a deploy of branch in the given deploy context.
+// The first matching rule wins, so rules from the branch-named context come first, followed by
+// the deploy context and finally [[redirects]].
+func (n *NetlifyToml) ResolveRedirects(context, branch string) []Redirect {
+	var out []Redirect
+	contexts := n.contexts(context, branch)
+	for i := len(contexts) - 1; i >= 0; i-- {
+		out = append(out, contexts[i].Redirects...)
+	}
+	return append(out, n.Redirects...)
+}
+
+// contexts returns the contexts that apply, in increasing order of precedence
+func (n *NetlifyToml) contexts(context, branch string) []DeployContext {
+	var out []DeployContext
+	if dc, ok := n.Context[context]; ok && context != "" {
+		out = append(out, dc)
+	}
+	if dc, ok := n.Context[branch]; ok && branch != "" && branch != context {
+		out = append(out, dc)
+	}
+	return out
+}
+
+func mergeBuild(dst, src *BuildConfig) {
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&dst.Command, src.Command},
+		{&dst.Base, src.Base},
+		{&dst.Publish, src.Publish},
+		{&dst.Ignore, src.Ignore},
+		{&dst.Functions, src.Functions},
+		{&dst.EdgeHandlers, src.EdgeHandlers},
+		{&dst.EdgeFunctions, src.EdgeFunctions},
+	} {
+		if f.src != "" {
+			*f.dst = f.src
+		}
+	}
+
+	if len(src.Environment) > 0 {
+		env := make(map[string]string, len(dst.Environment)+len(src.Environment))
+		for k, v := range dst.Environment {
+			env[k] = v
+		}
+		for k, v := range src.Environment {
+			env[k] = v
+		}
+		dst.Environment = env
+	}
+
+	if src.Processing != nil {
+		dst.Processing = mergeProcessing(dst.Processing, src.Processing)
+	}
+}
+
+func mergeProcessing(dst, src *ProcessingConfig) *ProcessingConfig {
+	out := new(ProcessingConfig)
+	if dst != nil {
+		*out = *dst
+	}
+	if src.SkipProcessing != nil {
+		out.SkipProcessing = src.SkipProcessing
+	}
+	if src.CSS != nil {
+		out.CSS = src.CSS
+	}
+	if src.JS != nil {
+		out.JS = src.JS
+	}
+	if src.HTML != nil {
+		out.HTML = src.HTML
+	}
+	if src.Images != nil {
+		out.Images = src.Images
+	}
+	return out
+}
+
+func mergePlugins(dst, src []Plugin) []Plugin {
+	for _, p := range src {
+		idx := -1
+		for i := range dst {
+			if dst[i].Package == p.Package {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			dst = append(dst, copyPlugin(p))
+			continue
+		}
+
+		if p.PinnedVersion != "" {
+			dst[idx].PinnedVersion = p.PinnedVersion
+		}
+		for k, v := range p.Inputs {
+			if dst[idx].Inputs == nil {
+				dst[idx].Inputs = make(map[string]interface{})
+			}
+			dst[idx].Inputs[k] = v
+		}
+	}
+	return dst
+}
+
+func copyPlugin(p Plugin) Plugin {
+	if p.Inputs != nil {
+		inputs := make(map[string]interface{}, len(p.Inputs))
+		for k, v := range p.Inputs {
+			inputs[k] = v
+		}
+		p.Inputs = inputs
+	}
+	return p
+}
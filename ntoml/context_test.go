@@ -0,0 +1,141 @@
+package ntoml
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const contextToml = `
+[build]
+  command = "npm run build"
+  publish = "dist"
+  [build.environment]
+    NODE_VERSION = "16"
+    API_URL = "https://api.example.com"
+
+[[plugins]]
+  package = "plugin-a"
+  [plugins.inputs]
+    level = "info"
+    strict = true
+
+[[redirects]]
+  from = "/base"
+  to = "/base.html"
+
+[context.production]
+  command = "npm run build:prod"
+  [context.production.environment]
+    NODE_ENV = "production"
+
+[context.deploy-preview.environment]
+  API_URL = "https://staging-api.example.com"
+
+[[context.deploy-preview.plugins]]
+  package = "plugin-a"
+  pinned_version = "2"
+  [context.deploy-preview.plugins.inputs]
+    level = "debug"
+
+[[context.deploy-preview.plugins]]
+  package = "plugin-b"
+
+[[context.deploy-preview.redirects]]
+  from = "/preview"
+  to = "/preview.html"
+
+[context.feature]
+  publish = "feature-dist"
+  [context.feature.environment]
+    API_URL = "https://feature-api.example.com"
+
+[[context.feature.redirects]]
+  from = "/feature"
+  to = "/feature.html"
+`
+
+func TestResolveBuild(t *testing.T) {
+	conf := new(NetlifyToml)
+	_, err := toml.Decode(contextToml, conf)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		context  string
+		branch   string
+		expected BuildConfig
+	}{
+		{"production", ContextProduction, "main", BuildConfig{
+			Command: "npm run build:prod",
+			Publish: "dist",
+			Environment: map[string]string{
+				"NODE_VERSION": "16",
+				"API_URL":      "https://api.example.com",
+				"NODE_ENV":     "production",
+			},
+		}},
+		{"deploy preview", ContextDeployPreview, "some-pr", BuildConfig{
+			Command: "npm run build",
+			Publish: "dist",
+			Environment: map[string]string{
+				"NODE_VERSION": "16",
+				"API_URL":      "https://staging-api.example.com",
+			},
+		}},
+		{"branch context wins", ContextDeployPreview, "feature", BuildConfig{
+			Command: "npm run build",
+			Publish: "feature-dist",
+			Environment: map[string]string{
+				"NODE_VERSION": "16",
+				"API_URL":      "https://feature-api.example.com",
+			},
+		}},
+		{"unknown context", ContextBranchDeploy, "other", BuildConfig{
+			Command: "npm run build",
+			Publish: "dist",
+			Environment: map[string]string{
+				"NODE_VERSION": "16",
+				"API_URL":      "https://api.example.com",
+			},
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, conf.ResolveBuild(tc.context, tc.branch))
+		})
+	}
+
+	// resolving must not modify the base config
+	assert.Len(t, conf.Build.Environment, 2)
+}
+
+func TestResolvePlugins(t *testing.T) {
+	conf := new(NetlifyToml)
+	_, err := toml.Decode(contextToml, conf)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Plugin{
+		{Package: "plugin-a", PinnedVersion: "2", Inputs: map[string]interface{}{"level": "debug", "strict": true}},
+		{Package: "plugin-b"},
+	}, conf.ResolvePlugins(ContextDeployPreview, "pr-1"))
+
+	assert.Equal(t, conf.Plugins, conf.ResolvePlugins(ContextProduction, "main"))
+	assert.Equal(t, "info", conf.Plugins[0].Inputs["level"])
+}
+
+func TestResolveRedirects(t *testing.T) {
+	conf := new(NetlifyToml)
+	_, err := toml.Decode(contextToml, conf)
+	require.NoError(t, err)
+
+	var sources []string
+	for _, r := range conf.ResolveRedirects(ContextDeployPreview, "feature") {
+		sources = append(sources, r.Source())
+	}
+	assert.Equal(t, []string{"/feature", "/preview", "/base"}, sources)
+
+	assert.Equal(t, conf.Redirects, conf.ResolveRedirects(ContextProduction, "main"))
+}
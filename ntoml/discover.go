@@ -0,0 +1,151 @@
+package ntoml
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DiscoveryReason is why a config file was picked.
+type DiscoveryReason int
+
+const (
+	// FoundInDirectory means the config is in the directory discovery started from.
+	FoundInDirectory DiscoveryReason = iota
+	// FoundInParent means the config is in a parent directory, up to the repository root.
+	FoundInParent
+	// FoundInBuildBase means the config was found through the build.base setting of another one.
+	FoundInBuildBase
+)
+
+func (r DiscoveryReason) String() string {
+	switch r {
+	case FoundInParent:
+		return "found in a parent directory"
+	case FoundInBuildBase:
+		return "found in the base directory"
+	default:
+		return "found in the package directory"
+	}
+}
+
+// Discovery describes how the config file of a directory was found.
+type Discovery struct {
+	// Path is the config file that was used.
+	Path   string
+	Reason DiscoveryReason
+	// Via is the config whose build.base led to Path, only set for FoundInBuildBase.
+	Via string
+	// Base is the base directory the build runs in: build.base resolved against the repository
+	// root, or the directory of Path when it isn't set.
+	Base string
+	// RepoRoot is the closest directory with a .git entry, empty when there is none.
+	RepoRoot string
+	// Checked lists every path that was looked at, in order.
+	Checked []string
+	// Ignored lists the config files that exist next to a used one but lost on precedence.
+	Ignored []string
+}
+
+func (d *Discovery) String() string {
+	msg := fmt.Sprintf("using %s, %s", d.Path, d.Reason)
+	if d.Reason == FoundInBuildBase {
+		msg += fmt.Sprintf(" set in %s", d.Via)
+	}
+	if len(d.Ignored) > 0 {
+		msg += fmt.Sprintf(", ignoring %s", strings.Join(d.Ignored, ", "))
+	}
+	return msg
+}
+
+// Discover finds and loads the config for dir. It looks in dir and then its parents, up to the
+// root of the git repository dir is in. Outside of a repository only dir itself is checked.
+// When the config that is found sets build.base and the base directory has a config of its
+// own, that one is used instead. The returned error is a *FoundNoConfigPathError when there
+// is no config.
+func Discover(dir string) (*NetlifyToml, *Discovery, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	d := &Discovery{RepoRoot: findRepoRoot(dir)}
+
+	for cur := dir; ; {
+		path, checked, ignored := findConfig(cur)
+		d.Checked = append(d.Checked, checked...)
+		if path != "" {
+			d.Path, d.Ignored = path, ignored
+			if cur != dir {
+				d.Reason = FoundInParent
+			}
+			break
+		}
+
+		parent := filepath.Dir(cur)
+		if d.RepoRoot == "" || cur == d.RepoRoot || parent == cur {
+			return nil, nil, &FoundNoConfigPathError{Base: dir, Checked: d.Checked}
+		}
+		cur = parent
+	}
+
+	conf, err := LoadFrom(d.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	d.Base = filepath.Dir(d.Path)
+	if conf.Build == nil || conf.Build.Base == "" {
+		return conf, d, nil
+	}
+
+	root := d.RepoRoot
+	if root == "" {
+		root = filepath.Dir(d.Path)
+	}
+	d.Base = filepath.Join(root, conf.Build.Base)
+	if d.Base == filepath.Dir(d.Path) {
+		return conf, d, nil
+	}
+
+	path, checked, ignored := findConfig(d.Base)
+	d.Checked = append(d.Checked, checked...)
+	if path == "" {
+		return conf, d, nil
+	}
+	if conf, err = LoadFrom(path); err != nil {
+		return nil, nil, err
+	}
+	d.Via, d.Path, d.Ignored, d.Reason = d.Path, path, ignored, FoundInBuildBase
+	return conf, d, nil
+}
+
+// findConfig checks dir for each of ConfigFilenames. It returns the one with the highest
+// precedence, the paths it checked and the config files that lost on precedence.
+func findConfig(dir string) (path string, checked, ignored []string) {
+	for _, name := range ConfigFilenames {
+		p := filepath.Join(dir, name)
+		checked = append(checked, p)
+		if fi, err := os.Stat(p); err != nil || fi.IsDir() {
+			continue
+		}
+		if path == "" {
+			path = p
+		} else {
+			ignored = append(ignored, p)
+		}
+	}
+	return path, checked, ignored
+}
+
+func findRepoRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,116 @@
+package ntoml
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, path, data string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(data), 0644))
+}
+
+func testRepo(t *testing.T) string {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.Mkdir(filepath.Join(root, ".git"), 0755))
+	return root
+}
+
+func TestDiscoverInDirectory(t *testing.T) {
+	root := testRepo(t)
+	writeTestFile(t, filepath.Join(root, "netlify.toml"), "[build]\n  command = \"root\"\n")
+	writeTestFile(t, filepath.Join(root, "netlify.json"), "{}")
+
+	conf, d, err := Discover(root)
+	require.NoError(t, err)
+	assert.Equal(t, "root", conf.Build.Command)
+	assert.Equal(t, &Discovery{
+		Path:     filepath.Join(root, "netlify.toml"),
+		Reason:   FoundInDirectory,
+		Base:     root,
+		RepoRoot: root,
+		Checked: []string{
+			filepath.Join(root, "netlify.toml"),
+			filepath.Join(root, "netlify.yml"),
+			filepath.Join(root, "netlify.yaml"),
+			filepath.Join(root, "netlify.json"),
+		},
+		Ignored: []string{filepath.Join(root, "netlify.json")},
+	}, d)
+	assert.Equal(t, "using "+filepath.Join(root, "netlify.toml")+", found in the package directory, ignoring "+filepath.Join(root, "netlify.json"), d.String())
+}
+
+func TestDiscoverWalksUp(t *testing.T) {
+	root := testRepo(t)
+	pkg := filepath.Join(root, "packages", "site")
+	require.NoError(t, os.MkdirAll(pkg, 0755))
+	writeTestFile(t, filepath.Join(root, "netlify.yml"), "build:\n  command: root\n")
+
+	conf, d, err := Discover(pkg)
+	require.NoError(t, err)
+	assert.Equal(t, "root", conf.Build.Command)
+	assert.Equal(t, filepath.Join(root, "netlify.yml"), d.Path)
+	assert.Equal(t, FoundInParent, d.Reason)
+	assert.Equal(t, root, d.Base)
+	assert.Len(t, d.Checked, 12)
+
+	// a config in the package directory is closer
+	writeTestFile(t, filepath.Join(pkg, "netlify.toml"), "[build]\n  command = \"site\"\n")
+	conf, d, err = Discover(pkg)
+	require.NoError(t, err)
+	assert.Equal(t, "site", conf.Build.Command)
+	assert.Equal(t, FoundInDirectory, d.Reason)
+}
+
+func TestDiscoverBuildBase(t *testing.T) {
+	root := testRepo(t)
+	writeTestFile(t, filepath.Join(root, "netlify.toml"), "[build]\n  base = \"packages/site\"\n")
+
+	conf, d, err := Discover(root)
+	require.NoError(t, err)
+	assert.Equal(t, "packages/site", conf.Build.Base)
+	assert.Equal(t, filepath.Join(root, "netlify.toml"), d.Path)
+	assert.Equal(t, filepath.Join(root, "packages", "site"), d.Base)
+
+	writeTestFile(t, filepath.Join(root, "packages", "site", "netlify.toml"), "[build]\n  command = \"site\"\n")
+	conf, d, err = Discover(root)
+	require.NoError(t, err)
+	assert.Equal(t, "site", conf.Build.Command)
+	assert.Equal(t, FoundInBuildBase, d.Reason)
+	assert.Equal(t, filepath.Join(root, "netlify.toml"), d.Via)
+	assert.Equal(t, filepath.Join(root, "packages", "site", "netlify.toml"), d.Path)
+	assert.Equal(t, filepath.Join(root, "packages", "site"), d.Base)
+	assert.Contains(t, d.String(), "found in the base directory set in "+filepath.Join(root, "netlify.toml"))
+}
+
+func TestDiscoverNotFound(t *testing.T) {
+	root := testRepo(t)
+	pkg := filepath.Join(root, "pkg")
+	require.NoError(t, os.Mkdir(pkg, 0755))
+
+	_, _, err := Discover(pkg)
+	require.IsType(t, &FoundNoConfigPathError{}, err)
+	nf := err.(*FoundNoConfigPathError)
+	assert.Equal(t, pkg, nf.Base)
+	assert.Equal(t, filepath.Join(pkg, "netlify.toml"), nf.Checked[0])
+	assert.Equal(t, filepath.Join(root, "netlify.json"), nf.Checked[len(nf.Checked)-1])
+	assert.Len(t, nf.Checked, 8)
+}
+
+func TestDiscoverOutsideRepository(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	writeTestFile(t, filepath.Join(dir, "netlify.toml"), "")
+	pkg := filepath.Join(dir, "pkg")
+	require.NoError(t, os.Mkdir(pkg, 0755))
+
+	_, _, err = Discover(pkg)
+	require.IsType(t, &FoundNoConfigPathError{}, err)
+	assert.Len(t, err.(*FoundNoConfigPathError).Checked, 4)
+}
@@ -0,0 +1,632 @@
+package ntoml
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// Document is the source of a netlify.toml file that can be edited in place. Edits only
+// touch the lines of the settings, plugins and redirects they change, all other content
+// including comments, key order and formatting is kept byte for byte.
+type Document struct {
+	lines []string
+	crlf  bool
+}
+
+// ParseDocument parses the source of a netlify.toml file.
+func ParseDocument(data []byte) (*Document, error) {
+	d := &Document{
+		lines: strings.Split(string(data), "\n"),
+		crlf:  bytes.Contains(data, []byte("\r\n")),
+	}
+	if _, err := d.Config(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// LoadDocument reads and parses the netlify.toml file at path.
+func LoadDocument(path string) (*Document, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error while reading in file %s", path)
+	}
+	d, err := ParseDocument(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error while decoding file %s", path)
+	}
+	return d, nil
+}
+
+// Bytes returns the source of the document.
+func (d *Document) Bytes() []byte {
+	return []byte(strings.Join(d.lines, "\n"))
+}
+
+// Config decodes the document.
+func (d *Document) Config() (*NetlifyToml, error) {
+	conf := new(NetlifyToml)
+	if err := toml.Unmarshal(d.Bytes(), conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// SaveTo writes the document to path. The file is replaced atomically, readers see either the
+// old or the new content.
+func (d *Document) SaveTo(path string) error {
+	return writeFileAtomic(path, d.Bytes())
+}
+
+// SetBuildSetting sets a key of the [build] table to value, which must be a string, number,
+// bool or an array of those. Dotted keys like environment.NODE_ENV address nested tables. An
+// existing key is changed in place, keeping its trailing comment, a new key is added after the
+// last key of its table.
+func (d *Document) SetBuildSetting(key string, value interface{}) error {
+	encoded, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+	return d.edit(func(lines []lineInfo) error {
+		d.setKey(lines, "build", splitKey(key), encoded)
+		return nil
+	})
+}
+
+// RemoveBuildSetting removes a key of the [build] table. It returns false when the key isn't set.
+func (d *Document) RemoveBuildSetting(key string) (bool, error) {
+	var found bool
+	err := d.edit(func(lines []lineInfo) error {
+		found = d.removeKey(lines, "build", splitKey(key))
+		return nil
+	})
+	return found, err
+}
+
+// AddPlugin adds a [[plugins]] entry after the existing ones.
+func (d *Document) AddPlugin(p Plugin) error {
+	conf, err := d.Config()
+	if err != nil {
+		return err
+	}
+	if pluginIndex(conf.Plugins, p.Package) != -1 {
+		return fmt.Errorf("plugin %q is already declared", p.Package)
+	}
+	rendered, err := renderArrayTable(struct {
+		Plugins []Plugin `toml:"plugins"`
+	}{[]Plugin{p}})
+	if err != nil {
+		return err
+	}
+	return d.edit(func(lines []lineInfo) error {
+		d.appendBlock(lines, "plugins", rendered)
+		return nil
+	})
+}
+
+// PinPlugin sets the pinned_version of a plugin. An empty version removes the pin.
+func (d *Document) PinPlugin(pkg, version string) error {
+	return d.editPlugin(pkg, func(lines []lineInfo, b block) {
+		if version == "" {
+			d.removeKeyIn(lines, b.start, []string{"pinned_version"})
+			return
+		}
+		encoded, _ := encodeValue(version)
+		d.setKeyIn(lines, b.start, []string{"pinned_version"}, encoded)
+	})
+}
+
+// RemovePlugin removes a plugin together with its inputs and the comments directly above it.
+func (d *Document) RemovePlugin(pkg string) error {
+	return d.editPlugin(pkg, func(lines []lineInfo, b block) {
+		d.removeBlock(lines, b)
+	})
+}
+
+// AddRedirect adds a [[redirects]] rule after the existing ones.
+func (d *Document) AddRedirect(r Redirect) error {
+	rendered, err := renderRedirect(r)
+	if err != nil {
+		return err
+	}
+	return d.edit(func(lines []lineInfo) error {
+		d.appendBlock(lines, "redirects", rendered)
+		return nil
+	})
+}
+
+// UpdateRedirect replaces the i-th [[redirects]] rule. The comments above the rule are kept.
+func (d *Document) UpdateRedirect(i int, r Redirect) error {
+	rendered, err := renderRedirect(r)
+	if err != nil {
+		return err
+	}
+	return d.editRedirect(i, func(lines []lineInfo, b block) {
+		d.replace(b.start, b.end, rendered)
+	})
+}
+
+// RemoveRedirect removes the i-th [[redirects]] rule and the comments directly above it.
+func (d *Document) RemoveRedirect(i int) error {
+	return d.editRedirect(i, func(lines []lineInfo, b block) {
+		d.removeBlock(lines, b)
+	})
+}
+
+func (d *Document) editPlugin(pkg string, fn func([]lineInfo, block)) error {
+	conf, err := d.Config()
+	if err != nil {
+		return err
+	}
+	i := pluginIndex(conf.Plugins, pkg)
+	if i == -1 {
+		return fmt.Errorf("plugin %q is not declared", pkg)
+	}
+	return d.edit(func(lines []lineInfo) error {
+		blocks := arrayBlocks(lines, "plugins")
+		if len(blocks) != len(conf.Plugins) {
+			return errors.New("plugins must be declared as [[plugins]] tables to be edited")
+		}
+		fn(lines, blocks[i])
+		return nil
+	})
+}
+
+func (d *Document) editRedirect(i int, fn func([]lineInfo, block)) error {
+	conf, err := d.Config()
+	if err != nil {
+		return err
+	}
+	if i < 0 || i >= len(conf.Redirects) {
+		return fmt.Errorf("redirect %d doesn't exist, there are %d redirects", i, len(conf.Redirects))
+	}
+	return d.edit(func(lines []lineInfo) error {
+		blocks := arrayBlocks(lines, "redirects")
+		if len(blocks) != len(conf.Redirects) {
+			return errors.New("redirects must be declared as [[redirects]] tables to be edited")
+		}
+		fn(lines, blocks[i])
+		return nil
+	})
+}
+
+// edit applies an edit and checks that the document still decodes, rolling back if it doesn't.
+func (d *Document) edit(fn func([]lineInfo) error) error {
+	before := append([]string(nil), d.lines...)
+	if err := fn(scanLines(d.lines)); err != nil {
+		return err
+	}
+	if _, err := d.Config(); err != nil {
+		d.lines = before
+		return errors.Wrap(err, "edit would result in an invalid document")
+	}
+	return nil
+}
+
+func (d *Document) setKey(lines []lineInfo, table string, key []string, value string) {
+	// find the most specific table that exists, e.g. [build.environment] for environment.NODE_ENV
+	for n := len(key) - 1; n >= 0; n-- {
+		header := tableHeader(lines, strings.Join(append([]string{table}, key[:n]...), "."))
+		if header == -1 {
+			continue
+		}
+		d.setKeyIn(lines, header, key[n:], value)
+		return
+	}
+
+	// the table doesn't exist, add it at the end of the document
+	d.insert(d.contentEnd(), append(d.separator(), "["+table+"]", "  "+formatKey(key)+" = "+value))
+}
+
+func (d *Document) setKeyIn(lines []lineInfo, header int, key []string, value string) {
+	end := tableEnd(lines, header)
+	path := strings.Join(key, ".")
+	lastKey := header
+	indent := "  "
+	for i := header + 1; i < end; i++ {
+		if lines[i].kind != lineKey {
+			continue
+		}
+		if lines[i].path == path {
+			d.setValue(lines[i], i, value)
+			return
+		}
+		lastKey = lines[i].end - 1
+		indent = leadingSpace(d.lines[i])
+	}
+	d.insert(lastKey+1, []string{indent + formatKey(key) + " = " + value})
+}
+
+func (d *Document) removeKey(lines []lineInfo, table string, key []string) bool {
+	for n := len(key) - 1; n >= 0; n-- {
+		header := tableHeader(lines, strings.Join(append([]string{table}, key[:n]...), "."))
+		if header != -1 && d.removeKeyIn(lines, header, key[n:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Document) removeKeyIn(lines []lineInfo, header int, key []string) bool {
+	path := strings.Join(key, ".")
+	for i := header + 1; i < tableEnd(lines, header); i++ {
+		if lines[i].kind == lineKey && lines[i].path == path {
+			d.replace(i, lines[i].end, nil)
+			return true
+		}
+	}
+	return false
+}
+
+// setValue replaces the value of the key at line i, keeping the spacing and a trailing comment.
+func (d *Document) setValue(info lineInfo, i int, value string) {
+	line := strings.TrimSuffix(d.lines[i], "\r")
+	eq := findUnquoted(line, '=')
+	start := eq + 1
+	for start < len(line) && (line[start] == ' ' || line[start] == '\t') {
+		start++
+	}
+
+	var rest string
+	if info.end == i+1 {
+		if c := findComment(line[start:]); c != -1 {
+			valueEnd := start + c
+			for valueEnd > start && (line[valueEnd-1] == ' ' || line[valueEnd-1] == '\t') {
+				valueEnd--
+			}
+			rest = line[valueEnd:]
+		}
+	}
+	d.replace(i, info.end, []string{line[:start] + value + rest})
+}
+
+// appendBlock inserts an array table entry after the last existing entry of name, or at the
+// end of the document when there is none.
+func (d *Document) appendBlock(lines []lineInfo, name string, rendered []string) {
+	at := d.contentEnd()
+	if blocks := arrayBlocks(lines, name); len(blocks) > 0 {
+		at = blocks[len(blocks)-1].end
+	}
+	d.insert(at, append([]string{""}, rendered...))
+	if at == 0 {
+		d.lines = d.lines[1:]
+	}
+}
+
+func (d *Document) removeBlock(lines []lineInfo, b block) {
+	start, end := b.lead, b.end
+	// drop one of the blank lines that separated the block from its neighbors
+	if start > 0 && strings.TrimSpace(d.lines[start-1]) == "" {
+		start--
+	} else if end < len(d.lines) && strings.TrimSpace(d.lines[end]) == "" && end+1 < len(d.lines) {
+		end++
+	}
+	d.replace(start, end, nil)
+}
+
+func (d *Document) replace(start, end int, with []string) {
+	if d.crlf {
+		for i := range with {
+			with[i] += "\r"
+		}
+	}
+	out := make([]string, 0, len(d.lines)-(end-start)+len(with))
+	out = append(out, d.lines[:start]...)
+	out = append(out, with...)
+	d.lines = append(out, d.lines[end:]...)
+}
+
+func (d *Document) insert(at int, with []string) {
+	d.replace(at, at, with)
+}
+
+// contentEnd returns the index after the last non-blank line.
+func (d *Document) contentEnd() int {
+	end := len(d.lines)
+	for end > 0 && strings.TrimSpace(d.lines[end-1]) == "" {
+		end--
+	}
+	return end
+}
+
+// separator returns the blank line to put before content appended to a non-empty document.
+func (d *Document) separator() []string {
+	if d.contentEnd() == 0 {
+		return nil
+	}
+	return []string{""}
+}
+
+type lineKind int
+
+const (
+	lineBlank lineKind = iota
+	lineComment
+	lineTable
+	lineArrayTable
+	lineKey
+	lineContinuation
+)
+
+type lineInfo struct {
+	kind lineKind
+	// path is the table name of headers and the dotted key of key lines, key holds its parts
+	path string
+	key  []string
+	// end is the index after the last line of a key's value
+	end int
+}
+
+// block is an entry of an array of tables. lead is the first of the comment lines directly
+// above its header, end is the index after its last line of content.
+type block struct {
+	lead, start, end int
+}
+
+func scanLines(src []string) []lineInfo {
+	lines := make([]lineInfo, len(src))
+	for i := 0; i < len(src); i++ {
+		text := strings.TrimSpace(src[i])
+		switch {
+		case text == "":
+			lines[i].kind = lineBlank
+		case text[0] == '#':
+			lines[i].kind = lineComment
+		case strings.HasPrefix(text, "[["):
+			lines[i] = newLineInfo(lineArrayTable, headerKey(text))
+		case text[0] == '[':
+			lines[i] = newLineInfo(lineTable, headerKey(text))
+		default:
+			eq := findUnquoted(text, '=')
+			if eq == -1 {
+				lines[i].kind = lineContinuation
+				continue
+			}
+			var s valueScanner
+			s.scan(text[eq+1:])
+			end := i + 1
+			for !s.done() && end < len(src) {
+				s.scan(src[end])
+				lines[end].kind = lineContinuation
+				end++
+			}
+			lines[i] = newLineInfo(lineKey, splitKey(text[:eq]))
+			lines[i].end = end
+			i = end - 1
+		}
+	}
+	return lines
+}
+
+func newLineInfo(kind lineKind, key []string) lineInfo {
+	return lineInfo{kind: kind, path: strings.Join(key, "."), key: key}
+}
+
+func headerKey(text string) []string {
+	name := strings.TrimLeft(text, "[")
+	if end := strings.Index(name, "]"); end != -1 {
+		name = name[:end]
+	}
+	return splitKey(name)
+}
+
+func tableHeader(lines []lineInfo, name string) int {
+	for i, l := range lines {
+		if l.kind == lineTable && l.path == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// tableEnd returns the index of the next header after the header at i.
+func tableEnd(lines []lineInfo, i int) int {
+	for i++; i < len(lines); i++ {
+		if lines[i].kind == lineTable || lines[i].kind == lineArrayTable {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+// arrayBlocks returns the top-level entries of the array of tables name. An entry includes its
+// sub-tables, like [plugins.inputs], but not the comments that lead the next header.
+func arrayBlocks(lines []lineInfo, name string) []block {
+	var blocks []block
+	for i := 0; i < len(lines); i++ {
+		if lines[i].kind != lineArrayTable || lines[i].path != name {
+			continue
+		}
+
+		b := block{lead: i, start: i}
+		for b.lead > 0 && lines[b.lead-1].kind == lineComment {
+			b.lead--
+		}
+		next := i + 1
+		for ; next < len(lines); next++ {
+			l := lines[next]
+			if (l.kind == lineTable || l.kind == lineArrayTable) && !strings.HasPrefix(l.path, name+".") {
+				break
+			}
+		}
+		b.end = next
+		if next < len(lines) {
+			for b.end > i+1 && lines[b.end-1].kind == lineComment {
+				b.end--
+			}
+		}
+		for b.end > i+1 && lines[b.end-1].kind == lineBlank {
+			b.end--
+		}
+		blocks = append(blocks, b)
+		i = next - 1
+	}
+	return blocks
+}
+
+// valueScanner follows a TOML value across lines to find where it ends.
+type valueScanner struct {
+	depth int
+	// multiline is the delimiter of an open multi-line string
+	multiline string
+}
+
+func (s *valueScanner) done() bool {
+	return s.depth <= 0 && s.multiline == ""
+}
+
+func (s *valueScanner) scan(line string) {
+	for i := 0; i < len(line); i++ {
+		if s.multiline != "" {
+			if line[i] == '\\' && s.multiline == `"""` {
+				i++
+			} else if strings.HasPrefix(line[i:], s.multiline) {
+				i += 2
+				s.multiline = ""
+			}
+			continue
+		}
+		switch c := line[i]; {
+		case strings.HasPrefix(line[i:], `"""`) || strings.HasPrefix(line[i:], `'''`):
+			s.multiline = line[i : i+3]
+			i += 2
+		case c == '"' || c == '\'':
+			i = skipString(line, i)
+		case c == '[' || c == '{':
+			s.depth++
+		case c == ']' || c == '}':
+			s.depth--
+		case c == '#':
+			return
+		}
+	}
+}
+
+// skipString returns the index of the quote closing the string that starts at i.
+func skipString(s string, i int) int {
+	quote := s[i]
+	for i++; i < len(s); i++ {
+		if s[i] == '\\' && quote == '"' {
+			i++
+		} else if s[i] == quote {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// findUnquoted returns the index of the first c in s that isn't inside a string.
+func findUnquoted(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case c:
+			return i
+		case '"', '\'':
+			i = skipString(s, i)
+		}
+	}
+	return -1
+}
+
+func findComment(s string) int {
+	return findUnquoted(s, '#')
+}
+
+func leadingSpace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
+
+// formatKey quotes the parts of a dotted key that aren't bare keys.
+func formatKey(key []string) string {
+	parts := make([]string, len(key))
+	for i, k := range key {
+		parts[i] = k
+		for _, c := range k {
+			if !(c == '-' || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+				parts[i] = fmt.Sprintf("%q", k)
+				break
+			}
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// encodeValue renders a value the way the TOML encoder would write it.
+func encodeValue(value interface{}) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(map[string]interface{}{"v": value}); err != nil {
+		return "", err
+	}
+	out := strings.TrimSpace(buf.String())
+	if !strings.HasPrefix(out, "v = ") {
+		return "", fmt.Errorf("can't set a value of type %T, only strings, numbers, bools and arrays of those", value)
+	}
+	return strings.TrimPrefix(out, "v = "), nil
+}
+
+func renderArrayTable(v interface{}) ([]string, error) {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSpace(buf.String()), "\n"), nil
+}
+
+func renderRedirect(r Redirect) ([]string, error) {
+	return renderArrayTable(struct {
+		Redirects []Redirect `toml:"redirects"`
+	}{[]Redirect{r}})
+}
+
+func pluginIndex(plugins []Plugin, pkg string) int {
+	for i, p := range plugins {
+		if p.Package == pkg {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it over path, so
+// the file is never left partially written. The permissions of an existing file are kept.
+func writeFileAtomic(path string, data []byte) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	perm := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		perm = fi.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "Failed to create a temporary file for %s", path)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "Failed to write file %s", tmp.Name())
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "Failed to write file %s", tmp.Name())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "Failed to write file %s", tmp.Name())
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return errors.Wrapf(err, "Failed to set permissions of %s", tmp.Name())
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrapf(err, "Failed to replace file %s", path)
+	}
+	return nil
+}
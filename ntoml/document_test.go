@@ -0,0 +1,204 @@
+package ntoml
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const documentSource = `# Site configuration, edited by hand.
+[build]
+    command = "make"   # keep in sync with the Makefile
+    publish = "dist"
+
+[build.environment]
+    NODE_VERSION = "16"
+
+# Lighthouse runs on every deploy
+[[plugins]]
+    package = "@netlify/plugin-lighthouse"
+    [plugins.inputs]
+        output_path = "reports/lighthouse.html"
+
+# sitemap, see https://example.com
+[[plugins]]
+    package = "netlify-plugin-sitemap"
+
+[[redirects]]
+    from = "/old"   # legacy
+    to = "/new"
+
+# proxy the API
+[[redirects]]
+    from = "/api/*"
+    to = "https://api.example.com/:splat"
+    status = 200
+`
+
+func parseTestDocument(t *testing.T) *Document {
+	d, err := ParseDocument([]byte(documentSource))
+	require.NoError(t, err)
+	return d
+}
+
+func TestDocumentUnchanged(t *testing.T) {
+	d := parseTestDocument(t)
+	assert.Equal(t, documentSource, string(d.Bytes()))
+}
+
+func TestDocumentSetBuildSetting(t *testing.T) {
+	d := parseTestDocument(t)
+	require.NoError(t, d.SetBuildSetting("command", "make all"))
+	require.NoError(t, d.SetBuildSetting("base", "site"))
+	require.NoError(t, d.SetBuildSetting("environment.NODE_VERSION", "18"))
+	require.NoError(t, d.SetBuildSetting("environment.NPM_FLAGS", "--prefer-offline"))
+
+	expected := strings.Replace(documentSource, `    command = "make"   # keep in sync with the Makefile
+    publish = "dist"
+`, `    command = "make all"   # keep in sync with the Makefile
+    publish = "dist"
+    base = "site"
+`, 1)
+	expected = strings.Replace(expected, `    NODE_VERSION = "16"
+`, `    NODE_VERSION = "18"
+    NPM_FLAGS = "--prefer-offline"
+`, 1)
+	assert.Equal(t, expected, string(d.Bytes()))
+
+	conf, err := d.Config()
+	require.NoError(t, err)
+	assert.Equal(t, "make all", conf.Build.Command)
+	assert.Equal(t, "site", conf.Build.Base)
+
+	found, err := d.RemoveBuildSetting("base")
+	require.NoError(t, err)
+	assert.True(t, found)
+	found, err = d.RemoveBuildSetting("environment.NPM_FLAGS")
+	require.NoError(t, err)
+	assert.True(t, found)
+	require.NoError(t, d.SetBuildSetting("environment.NODE_VERSION", "16"))
+	require.NoError(t, d.SetBuildSetting("command", "make"))
+	assert.Equal(t, documentSource, string(d.Bytes()))
+
+	found, err = d.RemoveBuildSetting("ignore")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestDocumentSetBuildSettingNewTable(t *testing.T) {
+	d, err := ParseDocument([]byte("# empty\n"))
+	require.NoError(t, err)
+	require.NoError(t, d.SetBuildSetting("publish", "public"))
+	require.NoError(t, d.SetBuildSetting("environment.GO_VERSION", "1.17"))
+	assert.Equal(t, "# empty\n\n[build]\n  publish = \"public\"\n  environment.GO_VERSION = \"1.17\"\n", string(d.Bytes()))
+
+	assert.Error(t, d.SetBuildSetting("processing", map[string]bool{"skip_processing": true}))
+}
+
+func TestDocumentPlugins(t *testing.T) {
+	d := parseTestDocument(t)
+	require.NoError(t, d.PinPlugin("@netlify/plugin-lighthouse", "4.1.0"))
+	require.NoError(t, d.AddPlugin(Plugin{Package: "netlify-plugin-cache", Inputs: map[string]interface{}{"paths": []string{".cache"}}}))
+
+	expected := strings.Replace(documentSource, `    package = "@netlify/plugin-lighthouse"
+`, `    package = "@netlify/plugin-lighthouse"
+    pinned_version = "4.1.0"
+`, 1)
+	expected = strings.Replace(expected, `    package = "netlify-plugin-sitemap"
+`, `    package = "netlify-plugin-sitemap"
+
+[[plugins]]
+  package = "netlify-plugin-cache"
+  [plugins.inputs]
+    paths = [".cache"]
+`, 1)
+	assert.Equal(t, expected, string(d.Bytes()))
+
+	assert.EqualError(t, d.AddPlugin(Plugin{Package: "netlify-plugin-cache"}), `plugin "netlify-plugin-cache" is already declared`)
+
+	require.NoError(t, d.RemovePlugin("netlify-plugin-cache"))
+	require.NoError(t, d.PinPlugin("@netlify/plugin-lighthouse", ""))
+	assert.Equal(t, documentSource, string(d.Bytes()))
+
+	require.NoError(t, d.RemovePlugin("@netlify/plugin-lighthouse"))
+	assert.NotContains(t, string(d.Bytes()), "Lighthouse")
+	assert.Contains(t, string(d.Bytes()), "[build.environment]\n    NODE_VERSION = \"16\"\n\n# sitemap")
+
+	assert.EqualError(t, d.RemovePlugin("unknown"), `plugin "unknown" is not declared`)
+}
+
+func TestDocumentRedirects(t *testing.T) {
+	d := parseTestDocument(t)
+	require.NoError(t, d.AddRedirect(Redirect{From: "/blog/*", To: "/news/:splat", Status: 301}))
+	require.NoError(t, d.UpdateRedirect(1, Redirect{From: "/api/*", To: "https://api.example.com/v2/:splat", Status: 200, Force: true}))
+
+	conf, err := d.Config()
+	require.NoError(t, err)
+	assert.Equal(t, []Redirect{
+		{From: "/old", To: "/new"},
+		{From: "/api/*", To: "https://api.example.com/v2/:splat", Status: 200, Force: true},
+		{From: "/blog/*", To: "/news/:splat", Status: 301},
+	}, conf.Redirects)
+	assert.Contains(t, string(d.Bytes()), "# proxy the API\n[[redirects]]\n  from = \"/api/*\"")
+	assert.True(t, strings.HasPrefix(string(d.Bytes()), documentSource[:strings.Index(documentSource, "# proxy the API")]))
+
+	require.NoError(t, d.RemoveRedirect(2))
+	require.NoError(t, d.RemoveRedirect(0))
+	assert.NotContains(t, string(d.Bytes()), "legacy")
+	assert.True(t, strings.HasSuffix(string(d.Bytes()), "    NODE_VERSION = \"16\"\n\n# Lighthouse runs on every deploy\n[[plugins]]\n    package = \"@netlify/plugin-lighthouse\"\n    [plugins.inputs]\n        output_path = \"reports/lighthouse.html\"\n\n# sitemap, see https://example.com\n[[plugins]]\n    package = \"netlify-plugin-sitemap\"\n\n# proxy the API\n[[redirects]]\n  from = \"/api/*\"\n  to = \"https://api.example.com/v2/:splat\"\n  status = 200\n  force = true\n"))
+
+	assert.Error(t, d.RemoveRedirect(5))
+}
+
+func TestDocumentMultilineValues(t *testing.T) {
+	src := "[build]\n  command = \"\"\"\n[not a table]\n\"\"\"\n  ignored = [\n    \"a\",\n  ]\n"
+	d, err := ParseDocument([]byte(src))
+	require.NoError(t, err)
+
+	require.NoError(t, d.SetBuildSetting("ignored", "git diff --quiet"))
+	require.NoError(t, d.SetBuildSetting("publish", "out"))
+	assert.Equal(t, "[build]\n  command = \"\"\"\n[not a table]\n\"\"\"\n  ignored = \"git diff --quiet\"\n  publish = \"out\"\n", string(d.Bytes()))
+}
+
+func TestDocumentCRLF(t *testing.T) {
+	d, err := ParseDocument([]byte("[build]\r\n  command = \"make\"\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, d.SetBuildSetting("command", "make all"))
+	require.NoError(t, d.SetBuildSetting("publish", "out"))
+	assert.Equal(t, "[build]\r\n  command = \"make all\"\r\n  publish = \"out\"\r\n", string(d.Bytes()))
+}
+
+func TestDocumentEditRollback(t *testing.T) {
+	d, err := ParseDocument([]byte("build = { command = \"make\" }\n"))
+	require.NoError(t, err)
+	assert.Error(t, d.SetBuildSetting("publish", "out"))
+	assert.Equal(t, "build = { command = \"make\" }\n", string(d.Bytes()))
+}
+
+func TestDocumentSaveTo(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, DefaultFilename)
+	require.NoError(t, ioutil.WriteFile(path, []byte(documentSource), 0600))
+
+	d, err := LoadDocument(path)
+	require.NoError(t, err)
+	require.NoError(t, d.PinPlugin("netlify-plugin-sitemap", "0.8.1"))
+	require.NoError(t, d.SaveTo(path))
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "    package = \"netlify-plugin-sitemap\"\n    pinned_version = \"0.8.1\"\n")
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary file is left behind")
+}
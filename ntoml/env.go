@@ -0,0 +1,201 @@
+package ntoml
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UndefinedVariablesError is returned when a string references variables that aren't set.
+// The references expand to an empty string.
+type UndefinedVariablesError struct {
+	Names []string
+}
+
+func (e *UndefinedVariablesError) Error() string {
+	return fmt.Sprintf("undefined variables: %s", strings.Join(e.Names, ", "))
+}
+
+// ExpandEnv replaces $VAR and ${VAR} in s with their value in env. ${VAR:-default} expands to
+// default when VAR is unset or empty, and $$ is a literal $. A $ that isn't followed by a
+// variable name is kept as is. When variables are undefined, the expanded string is returned
+// together with an *UndefinedVariablesError.
+func ExpandEnv(s string, env map[string]string) (string, error) {
+	e := expander{env: env}
+	out, err := e.expand(s)
+	if err != nil {
+		return "", err
+	}
+	return out, e.err()
+}
+
+type expander struct {
+	env       map[string]string
+	undefined []string
+}
+
+func (e *expander) expand(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end == -1 {
+				return "", fmt.Errorf("unterminated variable reference in %q", s)
+			}
+			name, def, hasDefault := s[i+2:i+end], "", false
+			if sep := strings.Index(name, ":-"); sep != -1 {
+				name, def, hasDefault = name[:sep], name[sep+2:], true
+			}
+			if !isVarName(name) {
+				return "", fmt.Errorf("invalid variable name %q in %q", name, s)
+			}
+			b.WriteString(e.lookup(name, def, hasDefault))
+			i += end
+		case isVarStart(next):
+			j := i + 1
+			for j < len(s) && isVarChar(s[j]) {
+				j++
+			}
+			b.WriteString(e.lookup(s[i+1:j], "", false))
+			i = j - 1
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+func (e *expander) lookup(name, def string, hasDefault bool) string {
+	value, ok := e.env[name]
+	if hasDefault && value == "" {
+		return def
+	}
+	if !ok {
+		e.undefined = append(e.undefined, name)
+	}
+	return value
+}
+
+// expandAll expands each of the strings in place, continuing past undefined variables.
+func (e *expander) expandAll(fields ...*string) error {
+	for _, f := range fields {
+		out, err := e.expand(*f)
+		if err != nil {
+			return err
+		}
+		*f = out
+	}
+	return nil
+}
+
+func (e *expander) err() error {
+	if len(e.undefined) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, name := range e.undefined {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return &UndefinedVariablesError{Names: names}
+}
+
+func isVarName(s string) bool {
+	if s == "" || !isVarStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isVarChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isVarStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isVarChar(c byte) bool {
+	return isVarStart(c) || (c >= '0' && c <= '9')
+}
+
+// ExpandEnv expands the variables in the command and paths of the build settings. The
+// environment itself is left alone, use ResolveEnvironment for that.
+func (b *BuildConfig) ExpandEnv(env map[string]string) error {
+	e := expander{env: env}
+	if err := e.expandAll(&b.Command, &b.Base, &b.Publish, &b.Ignore, &b.Functions, &b.EdgeHandlers, &b.EdgeFunctions); err != nil {
+		return err
+	}
+	return e.err()
+}
+
+// ExpandEnv expands the variables in the paths and destination of the rule.
+func (r *Redirect) ExpandEnv(env map[string]string) error {
+	e := expander{env: env}
+	if err := e.expandAll(&r.Origin, &r.Destination, &r.From, &r.To); err != nil {
+		return err
+	}
+	return e.err()
+}
+
+// ResolveEnvironment returns the environment for a build of branch in the given deploy context.
+// The external environments, like the process environment and variables set in the UI, are
+// applied in order, so later ones override earlier ones. The variables from the config file
+// override those, layered like in ResolveBuild: [build.environment], then the deploy context,
+// then the branch-named context. Values in the config file can reference variables of the
+// layers below them.
+func (n *NetlifyToml) ResolveEnvironment(context, branch string, external ...map[string]string) (map[string]string, error) {
+	env := make(map[string]string)
+	for _, ext := range external {
+		for k, v := range ext {
+			env[k] = v
+		}
+	}
+
+	layers := make([]map[string]string, 0, 3)
+	if n.Build != nil {
+		layers = append(layers, n.Build.Environment)
+	}
+	for _, dc := range n.contexts(context, branch) {
+		layers = append(layers, dc.Environment)
+	}
+
+	e := expander{}
+	for _, layer := range layers {
+		e.env = env
+		expanded := make(map[string]string, len(layer))
+		for k, v := range layer {
+			out, err := e.expand(v)
+			if err != nil {
+				return nil, err
+			}
+			expanded[k] = out
+		}
+		env = make(map[string]string, len(e.env)+len(expanded))
+		for k, v := range e.env {
+			env[k] = v
+		}
+		for k, v := range expanded {
+			env[k] = v
+		}
+	}
+	return env, e.err()
+}
@@ -0,0 +1,97 @@
+package ntoml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandEnv(t *testing.T) {
+	env := map[string]string{"HOST": "example.com", "DIR": "dist", "EMPTY": ""}
+
+	for _, tc := range []struct {
+		in, out string
+	}{
+		{"no variables", "no variables"},
+		{"https://$HOST/", "https://example.com/"},
+		{"${DIR}/assets", "dist/assets"},
+		{"$DIR_2", ""},
+		{"${EMPTY:-fallback}", "fallback"},
+		{"${MISSING:-fallback}", "fallback"},
+		{"${DIR:-fallback}", "dist"},
+		{"cost $$5", "cost $5"},
+		{"$$HOST", "$HOST"},
+		{"100$ and $1", "100$ and $1"},
+		{"trailing $", "trailing $"},
+	} {
+		out, err := ExpandEnv(tc.in, env)
+		if tc.in == "$DIR_2" {
+			assert.Equal(t, &UndefinedVariablesError{Names: []string{"DIR_2"}}, err)
+		} else {
+			assert.NoError(t, err, tc.in)
+		}
+		assert.Equal(t, tc.out, out, tc.in)
+	}
+}
+
+func TestExpandEnvErrors(t *testing.T) {
+	_, err := ExpandEnv("${HOST", nil)
+	assert.EqualError(t, err, `unterminated variable reference in "${HOST"`)
+
+	_, err = ExpandEnv("${1A}", nil)
+	assert.EqualError(t, err, `invalid variable name "1A" in "${1A}"`)
+
+	out, err := ExpandEnv("$B/$A/$B", nil)
+	assert.Equal(t, "//", out)
+	assert.EqualError(t, err, "undefined variables: A, B")
+}
+
+func TestBuildAndRedirectExpandEnv(t *testing.T) {
+	env := map[string]string{"OUT": "public", "API": "https://api.example.com"}
+
+	b := BuildConfig{Command: "make OUT=$OUT", Publish: "${OUT}", Environment: map[string]string{"X": "$OUT"}}
+	require.NoError(t, b.ExpandEnv(env))
+	assert.Equal(t, BuildConfig{Command: "make OUT=public", Publish: "public", Environment: map[string]string{"X": "$OUT"}}, b)
+
+	r := Redirect{From: "/api/*", To: "$API/:splat", Status: 200}
+	require.NoError(t, r.ExpandEnv(env))
+	assert.Equal(t, "https://api.example.com/:splat", r.To)
+
+	r = Redirect{From: "/$PREFIX/*", To: "/"}
+	assert.EqualError(t, r.ExpandEnv(env), "undefined variables: PREFIX")
+}
+
+func TestResolveEnvironment(t *testing.T) {
+	conf := &NetlifyToml{
+		Build: &BuildConfig{Environment: map[string]string{
+			"NODE_ENV": "development",
+			"PATH":     "$PATH:/opt/bin",
+		}},
+		Context: map[string]DeployContext{
+			ContextProduction: {BuildConfig: BuildConfig{Environment: map[string]string{
+				"NODE_ENV": "production",
+				"API_URL":  "https://${API_HOST}",
+			}}},
+			"main": {BuildConfig: BuildConfig{Environment: map[string]string{
+				"API_HOST": "main.example.com",
+			}}},
+		},
+	}
+	process := map[string]string{"PATH": "/usr/bin", "API_HOST": "process.example.com", "TOKEN": "a"}
+	ui := map[string]string{"API_HOST": "ui.example.com", "TOKEN": "b"}
+
+	env, err := conf.ResolveEnvironment(ContextProduction, "main", process, ui)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"PATH":     "/usr/bin:/opt/bin",
+		"NODE_ENV": "production",
+		"API_URL":  "https://ui.example.com",
+		"API_HOST": "main.example.com",
+		"TOKEN":    "b",
+	}, env)
+
+	env, err = conf.ResolveEnvironment(ContextDeployPreview, "feature")
+	assert.EqualError(t, err, "undefined variables: PATH")
+	assert.Equal(t, map[string]string{"PATH": ":/opt/bin", "NODE_ENV": "development"}, env)
+}
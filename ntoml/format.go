@@ -0,0 +1,72 @@
+package ntoml
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an encoding a Netlify config can be written in.
+type Format string
+
+const (
+	FormatTOML Format = "toml"
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// ConfigFilenames are the config file names looked for in a directory, in order of precedence.
+var ConfigFilenames = []string{DefaultFilename, "netlify.yml", "netlify.yaml", "netlify.json"}
+
+// FormatOf returns the format of a config file based on its extension. Unknown extensions are
+// treated as TOML.
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	default:
+		return FormatTOML
+	}
+}
+
+// Decode decodes a config in the given format into out.
+func Decode(data []byte, format Format, out *NetlifyToml) error {
+	switch format {
+	case FormatTOML:
+		return toml.Unmarshal(data, out)
+	case FormatYAML:
+		return yaml.Unmarshal(data, out)
+	case FormatJSON:
+		return json.Unmarshal(data, out)
+	default:
+		return fmt.Errorf("unknown config format: %s", format)
+	}
+}
+
+// Encode writes the config to w in the given format.
+func Encode(w io.Writer, format Format, conf *NetlifyToml) error {
+	switch format {
+	case FormatTOML:
+		return toml.NewEncoder(w).Encode(conf)
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(conf); err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(conf)
+	default:
+		return fmt.Errorf("unknown config format: %s", format)
+	}
+}
@@ -0,0 +1,148 @@
+package ntoml
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	formatTOML = `
+[build]
+  command = "make"
+  publish = "dist"
+
+[functions]
+  node_bundler = "esbuild"
+[functions.api]
+  included_files = ["data/**"]
+
+[[redirects]]
+  from = "/old"
+  to = "/new"
+  status = 301
+
+[[plugins]]
+  package = "netlify-plugin-a"
+  [plugins.inputs]
+    mode = "fast"
+
+[context.deploy-preview]
+  command = "make preview"
+  [context.deploy-preview.environment]
+    DEBUG = "1"
+`
+	formatYAML = `
+build:
+  command: make
+  publish: dist
+functions:
+  node_bundler: esbuild
+  api:
+    included_files: ["data/**"]
+redirects:
+  - from: /old
+    to: /new
+    status: 301
+plugins:
+  - package: netlify-plugin-a
+    inputs:
+      mode: fast
+context:
+  deploy-preview:
+    command: make preview
+    environment:
+      DEBUG: "1"
+`
+	formatJSON = `{
+  "build": {"command": "make", "publish": "dist"},
+  "functions": {"node_bundler": "esbuild", "api": {"included_files": ["data/**"]}},
+  "redirects": [{"from": "/old", "to": "/new", "status": 301}],
+  "plugins": [{"package": "netlify-plugin-a", "inputs": {"mode": "fast"}}],
+  "context": {
+    "deploy-preview": {"command": "make preview", "environment": {"DEBUG": "1"}}
+  }
+}`
+)
+
+func TestLoadFormats(t *testing.T) {
+	expected := &NetlifyToml{
+		Build: &BuildConfig{Command: "make", Publish: "dist"},
+		Functions: FunctionsConfig{
+			"*":   {NodeBundler: "esbuild"},
+			"api": {IncludedFiles: []string{"data/**"}},
+		},
+		Redirects: []Redirect{{From: "/old", To: "/new", Status: 301}},
+		Plugins:   []Plugin{{Package: "netlify-plugin-a", Inputs: map[string]interface{}{"mode": "fast"}}},
+		Context: map[string]DeployContext{
+			ContextDeployPreview: {BuildConfig: BuildConfig{
+				Command:     "make preview",
+				Environment: map[string]string{"DEBUG": "1"},
+			}},
+		},
+	}
+
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"netlify.toml": formatTOML,
+		"netlify.yaml": formatYAML,
+		"netlify.yml":  formatYAML,
+		"netlify.json": formatJSON,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			require.NoError(t, ioutil.WriteFile(path, []byte(data), 0664))
+
+			conf, err := LoadFrom(path)
+			require.NoError(t, err)
+			assert.Equal(t, expected, conf)
+
+			out := filepath.Join(dir, "out-"+name)
+			require.NoError(t, SaveTo(conf, out))
+			reloaded, err := LoadFrom(out)
+			require.NoError(t, err)
+			assert.Equal(t, conf, reloaded)
+		})
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	assert.Equal(t, FormatTOML, FormatOf("netlify.toml"))
+	assert.Equal(t, FormatYAML, FormatOf("/site/netlify.yml"))
+	assert.Equal(t, FormatYAML, FormatOf("netlify.YAML"))
+	assert.Equal(t, FormatJSON, FormatOf("netlify.json"))
+	assert.Equal(t, FormatTOML, FormatOf("config"))
+}
+
+func TestGetNetlifyConfigPathPrecedence(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := GetNetlifyConfigPath(dir)
+	require.IsType(t, &FoundNoConfigPathError{}, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "netlify.toml"),
+		filepath.Join(dir, "netlify.yml"),
+		filepath.Join(dir, "netlify.yaml"),
+		filepath.Join(dir, "netlify.json"),
+	}, err.(*FoundNoConfigPathError).Checked)
+	assert.EqualError(t, err, "No Netlify configuration file found in "+dir+", checked "+
+		filepath.Join(dir, "netlify.toml")+", "+filepath.Join(dir, "netlify.yml")+", "+
+		filepath.Join(dir, "netlify.yaml")+", "+filepath.Join(dir, "netlify.json"))
+
+	for _, name := range []string{"netlify.json", "netlify.yaml", "netlify.yml", "netlify.toml"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0664))
+
+		path, err := GetNetlifyConfigPath(dir)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, name), path)
+	}
+}
+
+func TestLoadEmptyYAML(t *testing.T) {
+	conf := new(NetlifyToml)
+	require.NoError(t, Decode(nil, FormatYAML, conf))
+	assert.Equal(t, &NetlifyToml{}, conf)
+}
@@ -0,0 +1,109 @@
+package ntoml
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// LoadHeadersFile parses the _headers file at path.
+func LoadHeadersFile(path string) ([]Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	headers, err := ParseHeaders(f)
+	if perr, ok := err.(*ParseError); ok {
+		perr.File = path
+	}
+	return headers, err
+}
+
+// ParseHeaders parses rules in the _headers format: a path on its own line, followed by
+// indented `Name: value` lines. Repeated header names are joined with ", ".
+func ParseHeaders(r io.Reader) ([]Header, error) {
+	var headers []Header
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		raw := scanner.Text()
+		text := strings.TrimSpace(raw)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if raw[0] != ' ' && raw[0] != '\t' {
+			if strings.Contains(text, " ") {
+				return nil, &ParseError{Line: line, Msg: fmt.Sprintf("invalid path %q", text)}
+			}
+			headers = append(headers, Header{For: text, Values: make(map[string]string)})
+			continue
+		}
+
+		if len(headers) == 0 {
+			return nil, &ParseError{Line: line, Msg: "header without a path"}
+		}
+		parts := strings.SplitN(text, ":", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, &ParseError{Line: line, Msg: fmt.Sprintf("invalid header %q", text)}
+		}
+		value := strings.TrimSpace(parts[1])
+
+		values := headers[len(headers)-1].Values
+		if prev, ok := values[name]; ok {
+			value = prev + ", " + value
+		}
+		values[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// WriteHeaders writes rules in the _headers format.
+func WriteHeaders(w io.Writer, headers []Header) error {
+	bw := bufio.NewWriter(w)
+	for _, h := range headers {
+		if h.For == "" {
+			return errors.New("header rule without a path")
+		}
+		if _, err := fmt.Fprintln(bw, h.For); err != nil {
+			return err
+		}
+		for _, name := range sortedKeys(h.Values) {
+			if _, err := fmt.Fprintf(bw, "  %s: %s\n", name, h.Values[name]); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// MergeHeaders combines the rules of a _headers file with the ones from netlify.toml. When both
+// set the same header for the same path, the _headers file wins.
+func MergeHeaders(fileHeaders, configHeaders []Header) []Header {
+	out := make([]Header, 0, len(fileHeaders)+len(configHeaders))
+	index := make(map[string]int)
+	for _, list := range [][]Header{fileHeaders, configHeaders} {
+		for _, h := range list {
+			i, ok := index[h.For]
+			if !ok {
+				index[h.For] = len(out)
+				out = append(out, Header{For: h.For, Values: make(map[string]string, len(h.Values))})
+				i = len(out) - 1
+			}
+			for name, value := range h.Values {
+				if _, exists := out[i].Values[name]; !exists {
+					out[i].Values[name] = value
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,81 @@
+package ntoml
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const headersFile = `# security headers
+/*
+  X-Frame-Options: DENY
+  Link: </style.css>; rel=preload
+  Link: </app.js>; rel=preload
+
+/templates/index.html
+	Cache-Control: public, max-age=3600
+`
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders(strings.NewReader(headersFile))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Header{
+		{For: "/*", Values: map[string]string{
+			"X-Frame-Options": "DENY",
+			"Link":            "</style.css>; rel=preload, </app.js>; rel=preload",
+		}},
+		{For: "/templates/index.html", Values: map[string]string{
+			"Cache-Control": "public, max-age=3600",
+		}},
+	}, headers)
+}
+
+func TestParseHeadersErrors(t *testing.T) {
+	tests := map[string]int{
+		"  X-Frame-Options: DENY\n": 1,
+		"/*\n\n  not a header\n":    3,
+		"/a path with spaces\n":     1,
+	}
+	for input, line := range tests {
+		_, err := ParseHeaders(strings.NewReader(input))
+		require.Error(t, err, input)
+		perr, ok := err.(*ParseError)
+		require.True(t, ok)
+		assert.Equal(t, line, perr.Line, input)
+	}
+}
+
+func TestWriteHeadersRoundTrip(t *testing.T) {
+	headers, err := ParseHeaders(strings.NewReader(headersFile))
+	require.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, WriteHeaders(buf, headers))
+	assert.Equal(t, "/*\n"+
+		"  Link: </style.css>; rel=preload, </app.js>; rel=preload\n"+
+		"  X-Frame-Options: DENY\n"+
+		"/templates/index.html\n"+
+		"  Cache-Control: public, max-age=3600\n", buf.String())
+
+	reparsed, err := ParseHeaders(buf)
+	require.NoError(t, err)
+	assert.Equal(t, headers, reparsed)
+}
+
+func TestMergeHeaders(t *testing.T) {
+	merged := MergeHeaders(
+		[]Header{{For: "/*", Values: map[string]string{"X-Frame-Options": "DENY"}}},
+		[]Header{
+			{For: "/*", Values: map[string]string{"X-Frame-Options": "SAMEORIGIN", "X-Robots-Tag": "noindex"}},
+			{For: "/api/*", Values: map[string]string{"Cache-Control": "no-cache"}},
+		},
+	)
+	assert.Equal(t, []Header{
+		{For: "/*", Values: map[string]string{"X-Frame-Options": "DENY", "X-Robots-Tag": "noindex"}},
+		{For: "/api/*", Values: map[string]string{"Cache-Control": "no-cache"}},
+	}, merged)
+}
@@ -1,100 +1,296 @@
 package ntoml
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
-	"path/filepath"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
 )
 
 const DefaultFilename = "netlify.toml"
 
 type NetlifyToml struct {
-	Settings Settings `toml:"settings"`
+	Settings Settings `toml:"settings" json:"settings" yaml:"settings"`
 
-	Redirects []Redirect `toml:"redirects,omitempty"`
+	Redirects []Redirect `toml:"redirects,omitempty" json:"redirects,omitempty" yaml:"redirects,omitempty"`
+	Headers   []Header   `toml:"headers,omitempty" json:"headers,omitempty" yaml:"headers,omitempty"`
 
 	// this is the default context
-	Build   *BuildConfig             `toml:"build"`
-	Plugins []Plugin                 `toml:"plugins"`
-	Context map[string]DeployContext `toml:"context,omitempty"`
+	Build         *BuildConfig             `toml:"build" json:"build,omitempty" yaml:"build,omitempty"`
+	Functions     FunctionsConfig          `toml:"functions,omitempty" json:"functions,omitempty" yaml:"functions,omitempty"`
+	EdgeFunctions []EdgeFunction           `toml:"edge_functions,omitempty" json:"edge_functions,omitempty" yaml:"edge_functions,omitempty"`
+	Dev           *DevConfig               `toml:"dev,omitempty" json:"dev,omitempty" yaml:"dev,omitempty"`
+	Plugins       []Plugin                 `toml:"plugins" json:"plugins,omitempty" yaml:"plugins,omitempty"`
+	Context       map[string]DeployContext `toml:"context,omitempty" json:"context,omitempty" yaml:"context,omitempty"`
 }
 
 type Settings struct {
-	ID   string `toml:"id"`
-	Path string `toml:"path"`
+	ID   string `toml:"id" json:"id" yaml:"id"`
+	Path string `toml:"path" json:"path" yaml:"path"`
 }
 
 type BuildConfig struct {
-	Command      string            `toml:"command"`
-	Base         string            `toml:"base"`
-	Publish      string            `toml:"publish"`
-	Ignore       string            `toml:"ignore"`
-	Environment  map[string]string `toml:"environment"`
-	Functions    string            `toml:"functions"`
-	EdgeHandlers string            `toml:"edge_handlers"`
+	Command       string            `toml:"command,omitempty" json:"command,omitempty" yaml:"command,omitempty"`
+	Base          string            `toml:"base,omitempty" json:"base,omitempty" yaml:"base,omitempty"`
+	Publish       string            `toml:"publish,omitempty" json:"publish,omitempty" yaml:"publish,omitempty"`
+	Ignore        string            `toml:"ignore,omitempty" json:"ignore,omitempty" yaml:"ignore,omitempty"`
+	Environment   map[string]string `toml:"environment,omitempty" json:"environment,omitempty" yaml:"environment,omitempty"`
+	Functions     string            `toml:"functions,omitempty" json:"functions,omitempty" yaml:"functions,omitempty"`
+	EdgeHandlers  string            `toml:"edge_handlers,omitempty" json:"edge_handlers,omitempty" yaml:"edge_handlers,omitempty"`
+	EdgeFunctions string            `toml:"edge_functions,omitempty" json:"edge_functions,omitempty" yaml:"edge_functions,omitempty"`
+	Processing    *ProcessingConfig `toml:"processing,omitempty" json:"processing,omitempty" yaml:"processing,omitempty"`
+}
+
+// ProcessingConfig is the [build.processing] table. The flags are pointers so an unset flag
+// can be told apart from one that is explicitly disabled.
+type ProcessingConfig struct {
+	SkipProcessing *bool             `toml:"skip_processing,omitempty" json:"skip_processing,omitempty" yaml:"skip_processing,omitempty"`
+	CSS            *BundleProcessing `toml:"css,omitempty" json:"css,omitempty" yaml:"css,omitempty"`
+	JS             *BundleProcessing `toml:"js,omitempty" json:"js,omitempty" yaml:"js,omitempty"`
+	HTML           *HTMLProcessing   `toml:"html,omitempty" json:"html,omitempty" yaml:"html,omitempty"`
+	Images         *ImageProcessing  `toml:"images,omitempty" json:"images,omitempty" yaml:"images,omitempty"`
+}
+
+type BundleProcessing struct {
+	Bundle *bool `toml:"bundle,omitempty" json:"bundle,omitempty" yaml:"bundle,omitempty"`
+	Minify *bool `toml:"minify,omitempty" json:"minify,omitempty" yaml:"minify,omitempty"`
+}
+
+type HTMLProcessing struct {
+	PrettyURLs *bool `toml:"pretty_urls,omitempty" json:"pretty_urls,omitempty" yaml:"pretty_urls,omitempty"`
+}
+
+type ImageProcessing struct {
+	Compress *bool `toml:"compress,omitempty" json:"compress,omitempty" yaml:"compress,omitempty"`
 }
 
 type Plugin struct {
-	Package       string `toml:"package" json:"package"`
-	PinnedVersion string `toml:"pinned_version,omitempty" json:"pinned_version,omitempty"`
+	Package       string                 `toml:"package" json:"package" yaml:"package"`
+	PinnedVersion string                 `toml:"pinned_version,omitempty" json:"pinned_version,omitempty" yaml:"pinned_version,omitempty"`
+	Inputs        map[string]interface{} `toml:"inputs,omitempty" json:"inputs,omitempty" yaml:"inputs,omitempty"`
 }
 
 type DeployContext struct {
+	// the build settings sit directly in the context table, toml and json flatten embedded
+	// structs by default
 	BuildConfig `yaml:",inline"`
+
+	Plugins   []Plugin   `toml:"plugins,omitempty" json:"plugins,omitempty" yaml:"plugins,omitempty"`
+	Redirects []Redirect `toml:"redirects,omitempty" json:"redirects,omitempty" yaml:"redirects,omitempty"`
 }
 
+// Redirect is a [[redirects]] rule. Netlify accepts both the from/to/query and the older
+// origin/destination/parameters spellings, use the Source, Target and Query methods to read
+// whichever is set.
 type Redirect struct {
-	Origin      string             `toml:"origin"`
-	Destination string             `toml:"destination"`
-	Parmeters   map[string]string  `toml:"parameters"`
-	Status      int                `toml:"status"`
-	Force       bool               `toml:"force"`
-	Conditions  *RedirectCondition `toml:"conditions"`
-	Headers     map[string]string  `toml:"headers"`
+	Origin      string             `toml:"origin,omitempty" json:"origin,omitempty" yaml:"origin,omitempty"`
+	Destination string             `toml:"destination,omitempty" json:"destination,omitempty" yaml:"destination,omitempty"`
+	Parmeters   map[string]string  `toml:"parameters,omitempty" json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	From        string             `toml:"from,omitempty" json:"from,omitempty" yaml:"from,omitempty"`
+	To          string             `toml:"to,omitempty" json:"to,omitempty" yaml:"to,omitempty"`
+	QueryParams map[string]string  `toml:"query,omitempty" json:"query,omitempty" yaml:"query,omitempty"`
+	Status      int                `toml:"status,omitzero" json:"status,omitempty" yaml:"status,omitempty"`
+	Force       bool               `toml:"force,omitempty" json:"force,omitempty" yaml:"force,omitempty"`
+	Signed      string             `toml:"signed,omitempty" json:"signed,omitempty" yaml:"signed,omitempty"`
+	Conditions  *RedirectCondition `toml:"conditions,omitempty" json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	Headers     map[string]string  `toml:"headers,omitempty" json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+// Source returns the path the rule matches on.
+func (r *Redirect) Source() string {
+	if r.From != "" {
+		return r.From
+	}
+	return r.Origin
+}
+
+// Target returns the path or URL the rule points to.
+func (r *Redirect) Target() string {
+	if r.To != "" {
+		return r.To
+	}
+	return r.Destination
+}
+
+// Query returns the query parameters the rule requires.
+func (r *Redirect) Query() map[string]string {
+	if r.QueryParams != nil {
+		return r.QueryParams
+	}
+	return r.Parmeters
 }
 
 type RedirectCondition struct {
-	Language []string `toml:"language"`
-	Country  []string `toml:"country"`
-	Role     []string `toml:"role"`
+	Language []string `toml:"language,omitempty" json:"language,omitempty" yaml:"language,omitempty"`
+	Country  []string `toml:"country,omitempty" json:"country,omitempty" yaml:"country,omitempty"`
+	Role     []string `toml:"role,omitempty" json:"role,omitempty" yaml:"role,omitempty"`
 }
 
+// Header is a [[headers]] rule, setting Values on every response matching the For path.
+type Header struct {
+	For    string            `toml:"for" json:"for" yaml:"for"`
+	Values map[string]string `toml:"values" json:"values" yaml:"values"`
+}
+
+// FunctionsConfig is the [functions] table keyed by function name glob. Settings directly
+// under [functions] apply to all functions and are stored under the "*" key, which is also
+// how they are written back.
+type FunctionsConfig map[string]FunctionConfig
+
+type FunctionConfig struct {
+	Directory           string   `toml:"directory,omitempty" json:"directory,omitempty" yaml:"directory,omitempty"`
+	NodeBundler         string   `toml:"node_bundler,omitempty" json:"node_bundler,omitempty" yaml:"node_bundler,omitempty"`
+	IncludedFiles       []string `toml:"included_files,omitempty" json:"included_files,omitempty" yaml:"included_files,omitempty"`
+	ExternalNodeModules []string `toml:"external_node_modules,omitempty" json:"external_node_modules,omitempty" yaml:"external_node_modules,omitempty"`
+	IgnoredNodeModules  []string `toml:"ignored_node_modules,omitempty" json:"ignored_node_modules,omitempty" yaml:"ignored_node_modules,omitempty"`
+	Schedule            string   `toml:"schedule,omitempty" json:"schedule,omitempty" yaml:"schedule,omitempty"`
+}
+
+// UnmarshalTOML implements toml.Unmarshaler
+func (f *FunctionsConfig) UnmarshalTOML(data interface{}) error {
+	return f.fromTable(data, decodeTable)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (f *FunctionsConfig) UnmarshalJSON(data []byte) error {
+	var table interface{}
+	if err := json.Unmarshal(data, &table); err != nil {
+		return err
+	}
+	return f.fromTable(table, decodeJSONTable)
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler
+func (f *FunctionsConfig) UnmarshalYAML(value *yaml.Node) error {
+	var table interface{}
+	if err := value.Decode(&table); err != nil {
+		return err
+	}
+	return f.fromTable(table, decodeJSONTable)
+}
+
+func (f *FunctionsConfig) fromTable(data interface{}, decode func(map[string]interface{}, interface{}) error) error {
+	table, ok := data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("functions must be a table, got %T", data)
+	}
+	if *f == nil {
+		*f = make(FunctionsConfig)
+	}
+
+	all := make(map[string]interface{})
+	for key, value := range table {
+		sub, ok := value.(map[string]interface{})
+		if !ok {
+			all[key] = value
+			continue
+		}
+		fc := (*f)[key]
+		if err := decode(sub, &fc); err != nil {
+			return errors.Wrapf(err, "Error while decoding functions.%s", key)
+		}
+		(*f)[key] = fc
+	}
+	if len(all) > 0 {
+		fc := (*f)["*"]
+		if err := decode(all, &fc); err != nil {
+			return errors.Wrap(err, "Error while decoding functions")
+		}
+		(*f)["*"] = fc
+	}
+	return nil
+}
+
+// decodeTable decodes a generic TOML table into a typed value by round-tripping it through
+// the encoder, which keeps the toml struct tags as the single source of truth.
+func decodeTable(table map[string]interface{}, v interface{}) error {
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(table); err != nil {
+		return err
+	}
+	_, err := toml.Decode(buf.String(), v)
+	return err
+}
+
+// decodeJSONTable is decodeTable for tables coming from JSON or YAML documents, which share
+// their struct tags.
+func decodeJSONTable(table map[string]interface{}, v interface{}) error {
+	data, err := json.Marshal(table)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// EdgeFunction is an [[edge_functions]] declaration.
+type EdgeFunction struct {
+	Function     string `toml:"function" json:"function" yaml:"function"`
+	Path         string `toml:"path,omitempty" json:"path,omitempty" yaml:"path,omitempty"`
+	ExcludedPath string `toml:"excludedPath,omitempty" json:"excludedPath,omitempty" yaml:"excludedPath,omitempty"`
+	Cache        string `toml:"cache,omitempty" json:"cache,omitempty" yaml:"cache,omitempty"`
+}
+
+// DevConfig is the [dev] table used by netlify dev.
+type DevConfig struct {
+	Framework     string    `toml:"framework,omitempty" json:"framework,omitempty" yaml:"framework,omitempty"`
+	Command       string    `toml:"command,omitempty" json:"command,omitempty" yaml:"command,omitempty"`
+	TargetPort    int       `toml:"targetPort,omitzero" json:"targetPort,omitempty" yaml:"targetPort,omitempty"`
+	Port          int       `toml:"port,omitzero" json:"port,omitempty" yaml:"port,omitempty"`
+	Publish       string    `toml:"publish,omitempty" json:"publish,omitempty" yaml:"publish,omitempty"`
+	Functions     string    `toml:"functions,omitempty" json:"functions,omitempty" yaml:"functions,omitempty"`
+	FunctionsPort int       `toml:"functionsPort,omitzero" json:"functionsPort,omitempty" yaml:"functionsPort,omitempty"`
+	AutoLaunch    *bool     `toml:"autoLaunch,omitempty" json:"autoLaunch,omitempty" yaml:"autoLaunch,omitempty"`
+	JWTSecret     string    `toml:"jwtSecret,omitempty" json:"jwtSecret,omitempty" yaml:"jwtSecret,omitempty"`
+	JWTRolePath   string    `toml:"jwtRolePath,omitempty" json:"jwtRolePath,omitempty" yaml:"jwtRolePath,omitempty"`
+	EnvFiles      []string  `toml:"envFiles,omitempty" json:"envFiles,omitempty" yaml:"envFiles,omitempty"`
+	HTTPS         *DevHTTPS `toml:"https,omitempty" json:"https,omitempty" yaml:"https,omitempty"`
+}
+
+type DevHTTPS struct {
+	CertFile string `toml:"certFile" json:"certFile" yaml:"certFile"`
+	KeyFile  string `toml:"keyFile" json:"keyFile" yaml:"keyFile"`
+}
+
+// FoundNoConfigPathError is returned when no config file was found. Checked lists every path
+// that was looked at, in order.
 type FoundNoConfigPathError struct {
-	base    string
-	checked string
+	Base    string
+	Checked []string
 }
 
 func (f *FoundNoConfigPathError) Error() string {
-	return fmt.Sprintf("No Netlify configuration file found.")
+	return fmt.Sprintf("No Netlify configuration file found in %s, checked %s", f.Base, strings.Join(f.Checked, ", "))
 }
 
+// GetNetlifyConfigPath returns the path of the config file in base. When there are several,
+// the first one in ConfigFilenames wins.
 func GetNetlifyConfigPath(base string) (path string, err error) {
-	filePath := filepath.Join(base, DefaultFilename)
-
-	if fi, err := os.Stat(filePath); err == nil && !fi.IsDir() {
-		return filePath, nil
-	} else {
-		return "", &FoundNoConfigPathError{base: base, checked: DefaultFilename}
+	path, checked, _ := findConfig(base)
+	if path == "" {
+		return "", &FoundNoConfigPathError{Base: base, Checked: checked}
 	}
+	return path, nil
 }
 
+// Load loads the config for the working directory, see Discover.
 func Load() (*NetlifyToml, error) {
 	wd, err := os.Getwd()
 	if err != nil {
 		return nil, err
 	}
-	configPath, err := GetNetlifyConfigPath(wd)
-	if err != nil {
-		return nil, err
-	}
-	return LoadFrom(configPath)
+	conf, _, err := Discover(wd)
+	return conf, err
 }
 
+// LoadFrom loads the first of paths that exists. The format is picked by the file extension,
+// see FormatOf.
 func LoadFrom(paths ...string) (*NetlifyToml, error) {
 	if len(paths) == 0 {
 		return nil, errors.New("No paths specified")
@@ -108,7 +304,7 @@ func LoadFrom(paths ...string) (*NetlifyToml, error) {
 				return nil, errors.Wrapf(ferr, "Error while reading in file %s", p)
 			}
 
-			if derr := toml.Unmarshal(data, out); derr != nil {
+			if derr := Decode(data, FormatOf(p), out); derr != nil {
 				return nil, errors.Wrapf(derr, "Error while decoding file %s", p)
 			}
 
@@ -126,17 +322,12 @@ func Save(conf *NetlifyToml) error {
 	return SaveTo(conf, path.Join(wd, DefaultFilename))
 }
 
+// SaveTo writes the config to path, in the format matching its extension. The file is replaced
+// atomically.
 func SaveTo(conf *NetlifyToml, path string) error {
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
-	if err != nil {
-		return errors.Wrapf(err, "Failed to open file %s", path)
-	}
-
-	defer f.Close()
-
-	if err := toml.NewEncoder(f).Encode(conf); err != nil {
-		return errors.Wrap(err, "Failed to encode the toml file")
+	buf := new(bytes.Buffer)
+	if err := Encode(buf, FormatOf(path), conf); err != nil {
+		return errors.Wrapf(err, "Failed to encode the %s file", FormatOf(path))
 	}
-
-	return nil
+	return writeFileAtomic(path, buf.Bytes())
 }
@@ -3,6 +3,7 @@ package ntoml
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -33,10 +34,10 @@ func TestLoadingExampleToml(t *testing.T) {
 			Command: "echo 'not a thing'",
 		},
 		Context: map[string]DeployContext{
-			"deploy-preview": {BuildConfig{
+			"deploy-preview": {BuildConfig: BuildConfig{
 				Command: "hugo version && npm run build-preview",
 			}},
-			"branch-deploy": {BuildConfig{
+			"branch-deploy": {BuildConfig: BuildConfig{
 				Command:     "hugo version && npm run build-branch",
 				Environment: map[string]string{"HUGO_VERSION": "0.20.5"},
 			}},
@@ -116,3 +117,103 @@ func TestGetNetlifyConfigPath(t *testing.T) {
 	err = os.Remove("netlify.toml")
 	assert.NoError(t, err)
 }
+
+func TestFullSchemaRoundTrip(t *testing.T) {
+	data := `
+[build]
+  command = "npm run build"
+  publish = "dist"
+  edge_functions = "edge"
+
+  [build.processing]
+    skip_processing = false
+  [build.processing.css]
+    bundle = true
+    minify = false
+  [build.processing.html]
+    pretty_urls = true
+
+[functions]
+  directory = "functions/"
+  node_bundler = "esbuild"
+  external_node_modules = ["sharp"]
+
+[functions."api_*"]
+  included_files = ["data/**"]
+
+[[edge_functions]]
+  path = "/admin/*"
+  function = "auth"
+
+[dev]
+  command = "npm start"
+  port = 8888
+  targetPort = 3000
+  autoLaunch = false
+
+[[headers]]
+  for = "/*"
+  [headers.values]
+    X-Frame-Options = "DENY"
+
+[[redirects]]
+  from = "/old/*"
+  to = "/new/:splat"
+  status = 301
+  [redirects.query]
+    id = ":id"
+
+[[plugins]]
+  package = "@netlify/plugin-lighthouse"
+  [plugins.inputs]
+    output_path = "reports/lighthouse.html"
+    thresholds = { performance = 0.9 }
+
+[context.production.environment]
+  NODE_ENV = "production"
+`
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.toml")
+	require.NoError(t, ioutil.WriteFile(in, []byte(data), 0664))
+
+	conf, err := LoadFrom(in)
+	require.NoError(t, err)
+
+	f := false
+	tr := true
+	assert.Equal(t, &ProcessingConfig{
+		SkipProcessing: &f,
+		CSS:            &BundleProcessing{Bundle: &tr, Minify: &f},
+		HTML:           &HTMLProcessing{PrettyURLs: &tr},
+	}, conf.Build.Processing)
+	assert.Equal(t, "edge", conf.Build.EdgeFunctions)
+	assert.Equal(t, FunctionsConfig{
+		"*":     {Directory: "functions/", NodeBundler: "esbuild", ExternalNodeModules: []string{"sharp"}},
+		"api_*": {IncludedFiles: []string{"data/**"}},
+	}, conf.Functions)
+	assert.Equal(t, []EdgeFunction{{Path: "/admin/*", Function: "auth"}}, conf.EdgeFunctions)
+	assert.Equal(t, &DevConfig{Command: "npm start", Port: 8888, TargetPort: 3000, AutoLaunch: &f}, conf.Dev)
+	assert.Equal(t, []Header{{For: "/*", Values: map[string]string{"X-Frame-Options": "DENY"}}}, conf.Headers)
+
+	require.Len(t, conf.Redirects, 1)
+	assert.Equal(t, "/old/*", conf.Redirects[0].Source())
+	assert.Equal(t, "/new/:splat", conf.Redirects[0].Target())
+	assert.Equal(t, map[string]string{"id": ":id"}, conf.Redirects[0].Query())
+
+	require.Len(t, conf.Plugins, 1)
+	assert.Equal(t, "reports/lighthouse.html", conf.Plugins[0].Inputs["output_path"])
+	assert.Equal(t, "production", conf.Context["production"].Environment["NODE_ENV"])
+
+	out := filepath.Join(dir, "out.toml")
+	require.NoError(t, SaveTo(conf, out))
+	reloaded, err := LoadFrom(out)
+	require.NoError(t, err)
+	assert.Equal(t, conf, reloaded)
+}
+
+func TestRedirectLegacyFields(t *testing.T) {
+	r := Redirect{Origin: "/a", Destination: "/b", Parmeters: map[string]string{"q": ":q"}}
+	assert.Equal(t, "/a", r.Source())
+	assert.Equal(t, "/b", r.Target())
+	assert.Equal(t, map[string]string{"q": ":q"}, r.Query())
+}
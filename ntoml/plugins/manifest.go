@@ -0,0 +1,181 @@
+// Package plugins reads the manifests of Netlify build plugins and checks the inputs a config
+// passes to them.
+package plugins
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/netlify/netlify-commons/ntoml"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFilenames are the names a plugin manifest can have, in order of precedence.
+var ManifestFilenames = []string{"manifest.yml", "manifest.yaml"}
+
+// InputType is the type of value an input accepts.
+type InputType string
+
+const (
+	TypeString  InputType = "string"
+	TypeNumber  InputType = "number"
+	TypeBoolean InputType = "boolean"
+	TypeArray   InputType = "array"
+	TypeObject  InputType = "object"
+)
+
+// Manifest is a plugin's manifest.yml.
+type Manifest struct {
+	Name   string  `yaml:"name"`
+	Inputs []Input `yaml:"inputs"`
+
+	// Path is the file the manifest was loaded from.
+	Path string `yaml:"-"`
+}
+
+// Input declares an input of a plugin. When Type isn't set it is derived from the default, and
+// inputs without either accept any value.
+type Input struct {
+	Name        string      `yaml:"name"`
+	Description string      `yaml:"description,omitempty"`
+	Required    bool        `yaml:"required,omitempty"`
+	Default     interface{} `yaml:"default,omitempty"`
+	Type        InputType   `yaml:"type,omitempty"`
+
+	// Line is where the input is declared in the manifest.
+	Line int `yaml:"-"`
+}
+
+// Input returns the declared input with the given name.
+func (m *Manifest) Input(name string) (Input, bool) {
+	for _, in := range m.Inputs {
+		if in.Name == name {
+			return in, true
+		}
+	}
+	return Input{}, false
+}
+
+// LoadManifest loads the manifest in a plugin's directory. The returned error satisfies
+// os.IsNotExist when the directory has no manifest.
+func LoadManifest(dir string) (*Manifest, error) {
+	for _, name := range ManifestFilenames {
+		path := filepath.Join(dir, name)
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		m, err := ParseManifest(data)
+		if err != nil {
+			if perr, ok := err.(*ntoml.ParseError); ok {
+				perr.File = path
+			}
+			return nil, err
+		}
+		m.Path = path
+		return m, nil
+	}
+	return nil, &os.PathError{Op: "open", Path: filepath.Join(dir, ManifestFilenames[0]), Err: os.ErrNotExist}
+}
+
+// PluginDir returns the directory of a plugin. Packages starting with . or / are local plugins
+// relative to base, others are looked up in base/node_modules.
+func PluginDir(base string, p ntoml.Plugin) string {
+	switch {
+	case filepath.IsAbs(p.Package):
+		return p.Package
+	case strings.HasPrefix(p.Package, "."):
+		return filepath.Join(base, p.Package)
+	default:
+		return filepath.Join(base, "node_modules", filepath.FromSlash(p.Package))
+	}
+}
+
+// ParseManifest parses a manifest and checks its input declarations. Errors are returned as
+// *ntoml.ParseError, with the line of the offending declaration.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, &ntoml.ParseError{Msg: err.Error()}
+	}
+	m := new(Manifest)
+	if err := doc.Decode(m); err != nil {
+		return nil, &ntoml.ParseError{Msg: err.Error()}
+	}
+
+	lines := inputLines(&doc)
+	seen := make(map[string]bool)
+	for i := range m.Inputs {
+		in := &m.Inputs[i]
+		if i < len(lines) {
+			in.Line = lines[i]
+		}
+		switch {
+		case in.Name == "":
+			return nil, &ntoml.ParseError{Line: in.Line, Msg: "input without a name"}
+		case seen[in.Name]:
+			return nil, &ntoml.ParseError{Line: in.Line, Msg: fmt.Sprintf("input %q is declared more than once", in.Name)}
+		}
+		seen[in.Name] = true
+
+		if in.Type == "" {
+			if in.Default != nil {
+				in.Type = typeOf(in.Default)
+			}
+			continue
+		}
+		switch in.Type {
+		case TypeString, TypeNumber, TypeBoolean, TypeArray, TypeObject:
+		default:
+			return nil, &ntoml.ParseError{Line: in.Line, Msg: fmt.Sprintf("input %q has unknown type %q", in.Name, in.Type)}
+		}
+		if in.Default != nil && typeOf(in.Default) != in.Type {
+			return nil, &ntoml.ParseError{Line: in.Line, Msg: fmt.Sprintf("default of input %q is not a %s", in.Name, in.Type)}
+		}
+	}
+	return m, nil
+}
+
+// inputLines returns the line of each entry of the inputs list.
+func inputLines(doc *yaml.Node) []int {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "inputs" {
+			continue
+		}
+		var lines []int
+		for _, n := range root.Content[i+1].Content {
+			lines = append(lines, n.Line)
+		}
+		return lines
+	}
+	return nil
+}
+
+// typeOf returns the input type of a decoded TOML, YAML or JSON value.
+func typeOf(v interface{}) InputType {
+	switch v.(type) {
+	case string:
+		return TypeString
+	case bool:
+		return TypeBoolean
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return TypeNumber
+	case []interface{}, []map[string]interface{}:
+		return TypeArray
+	case map[string]interface{}:
+		return TypeObject
+	default:
+		return ""
+	}
+}
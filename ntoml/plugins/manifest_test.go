@@ -0,0 +1,79 @@
+package plugins
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/netlify/netlify-commons/ntoml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifest = `name: netlify-plugin-test
+inputs:
+  - name: output_path
+    description: Where to write the report
+    required: true
+  - name: retries
+    default: 3
+  - name: formats
+    type: array
+    default: [html]
+  - name: options
+    type: object
+`
+
+func TestParseManifest(t *testing.T) {
+	m, err := ParseManifest([]byte(testManifest))
+	require.NoError(t, err)
+
+	assert.Equal(t, &Manifest{
+		Name: "netlify-plugin-test",
+		Inputs: []Input{
+			{Name: "output_path", Description: "Where to write the report", Required: true, Line: 3},
+			{Name: "retries", Default: 3, Type: TypeNumber, Line: 6},
+			{Name: "formats", Default: []interface{}{"html"}, Type: TypeArray, Line: 8},
+			{Name: "options", Type: TypeObject, Line: 11},
+		},
+	}, m)
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	for manifest, msg := range map[string]string{
+		"inputs:\n  - description: x\n":                                      "line 2: input without a name",
+		"inputs:\n  - name: a\n  - name: a\n":                                `line 3: input "a" is declared more than once`,
+		"inputs:\n  - name: a\n    type: date\n":                             `line 2: input "a" has unknown type "date"`,
+		"inputs:\n  - name: a\n    type: boolean\n    default: yes please\n": `line 2: default of input "a" is not a boolean`,
+	} {
+		_, err := ParseManifest([]byte(manifest))
+		require.IsType(t, &ntoml.ParseError{}, err, manifest)
+		assert.EqualError(t, err, msg)
+	}
+
+	_, err := ParseManifest([]byte("inputs: [\n"))
+	assert.Error(t, err)
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	_, err := LoadManifest(dir)
+	assert.True(t, os.IsNotExist(err))
+
+	path := filepath.Join(dir, "manifest.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(testManifest), 0644))
+	m, err := LoadManifest(dir)
+	require.NoError(t, err)
+	assert.Equal(t, path, m.Path)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("inputs:\n  - name: a\n  - name: a\n"), 0644))
+	_, err = LoadManifest(dir)
+	assert.EqualError(t, err, path+`:3: input "a" is declared more than once`)
+}
+
+func TestPluginDir(t *testing.T) {
+	assert.Equal(t, filepath.Join("/site", "plugins", "local"), PluginDir("/site", ntoml.Plugin{Package: "./plugins/local"}))
+	assert.Equal(t, "/opt/plugin", PluginDir("/site", ntoml.Plugin{Package: "/opt/plugin"}))
+	assert.Equal(t, filepath.Join("/site", "node_modules", "@netlify", "plugin-lighthouse"), PluginDir("/site", ntoml.Plugin{Package: "@netlify/plugin-lighthouse"}))
+}
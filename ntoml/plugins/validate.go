@@ -0,0 +1,159 @@
+package plugins
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/netlify/netlify-commons/ntoml"
+	"github.com/pkg/errors"
+)
+
+// ResolveInputs checks inputs against the manifest and returns them with the defaults of the
+// inputs that aren't set filled in. path is the key path of the inputs in the config, like
+// plugins[0].inputs, and is used for the diagnostics. Unknown inputs, missing required inputs
+// and values of the wrong type are errors.
+func (m *Manifest) ResolveInputs(path string, inputs map[string]interface{}) (map[string]interface{}, []ntoml.Diagnostic) {
+	var diags []ntoml.Diagnostic
+	resolved := make(map[string]interface{}, len(m.Inputs))
+
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := inputs[name]
+		in, ok := m.Input(name)
+		if !ok {
+			diags = append(diags, ntoml.Diagnostic{
+				Severity: ntoml.SeverityError,
+				Path:     path + "." + name,
+				Message:  fmt.Sprintf("plugin %s has no input %q, it accepts %s", m.Name, name, m.inputNames()),
+			})
+			continue
+		}
+		if in.Type != "" && typeOf(value) != in.Type {
+			diags = append(diags, ntoml.Diagnostic{
+				Severity: ntoml.SeverityError,
+				Path:     path + "." + name,
+				Message:  fmt.Sprintf("input %q of plugin %s must be a %s", name, m.Name, in.Type),
+			})
+			continue
+		}
+		resolved[name] = value
+	}
+
+	for _, in := range m.Inputs {
+		if _, ok := inputs[in.Name]; ok {
+			continue
+		}
+		switch {
+		case in.Default != nil:
+			resolved[in.Name] = in.Default
+		case in.Required:
+			diags = append(diags, ntoml.Diagnostic{
+				Severity: ntoml.SeverityError,
+				Path:     path,
+				Message:  fmt.Sprintf("plugin %s requires input %q", m.Name, in.Name),
+			})
+		}
+	}
+	return resolved, diags
+}
+
+func (m *Manifest) inputNames() string {
+	if len(m.Inputs) == 0 {
+		return "no inputs"
+	}
+	names := make([]string, len(m.Inputs))
+	for i, in := range m.Inputs {
+		names[i] = fmt.Sprintf("%q", in.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Validate checks the inputs of every plugin in conf, including those declared in contexts,
+// against the plugin manifests. Plugins are resolved relative to base, see PluginDir. Plugins
+// that aren't installed can't be checked and get a warning.
+func Validate(conf *ntoml.NetlifyToml, base string) []ntoml.Diagnostic {
+	manifests := make(map[string]*Manifest)
+	var diags []ntoml.Diagnostic
+
+	check := func(prefix string, plugins []ntoml.Plugin) {
+		for i, p := range plugins {
+			path := fmt.Sprintf("%s[%d]", prefix, i)
+			m, ok := manifests[p.Package]
+			if !ok {
+				var err error
+				m, err = LoadManifest(PluginDir(base, p))
+				if err != nil {
+					diags = append(diags, manifestDiagnostic(path, p, err))
+				}
+				manifests[p.Package] = m
+			}
+			if m == nil {
+				continue
+			}
+			_, problems := m.ResolveInputs(path+".inputs", p.Inputs)
+			diags = append(diags, problems...)
+		}
+	}
+
+	check("plugins", conf.Plugins)
+	names := make([]string, 0, len(conf.Context))
+	for name := range conf.Context {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		check("context."+name+".plugins", conf.Context[name].Plugins)
+	}
+	return diags
+}
+
+func manifestDiagnostic(path string, p ntoml.Plugin, err error) ntoml.Diagnostic {
+	d := ntoml.Diagnostic{Severity: ntoml.SeverityError, Path: path + ".package"}
+	switch e := err.(type) {
+	case *ntoml.ParseError:
+		d.Message = fmt.Sprintf("invalid manifest of plugin %s: %s", p.Package, e)
+	default:
+		if os.IsNotExist(err) {
+			d.Severity = ntoml.SeverityWarning
+			d.Message = fmt.Sprintf("plugin %s has no manifest, it may not be installed, its inputs are not checked", p.Package)
+		} else {
+			d.Message = fmt.Sprintf("failed to load the manifest of plugin %s: %s", p.Package, err)
+		}
+	}
+	return d
+}
+
+// ValidateFile loads the config at path and validates the plugin inputs with Validate,
+// resolving plugins relative to the directory of the config. For TOML files the diagnostics
+// point at the offending line.
+func ValidateFile(path string) ([]ntoml.Diagnostic, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error while reading in file %s", path)
+	}
+	conf := new(ntoml.NetlifyToml)
+	if err := ntoml.Decode(data, ntoml.FormatOf(path), conf); err != nil {
+		return nil, errors.Wrapf(err, "Error while decoding file %s", path)
+	}
+
+	diags := Validate(conf, filepath.Dir(path))
+	var positions ntoml.KeyPositions
+	if ntoml.FormatOf(path) == ntoml.FormatTOML {
+		positions = ntoml.ScanKeyPositions(data)
+	}
+	for i := range diags {
+		diags[i].File = path
+		if pos, ok := positions.Lookup(diags[i].Path); ok {
+			diags[i].Line, diags[i].Column = pos.Line, pos.Column
+		}
+	}
+	return diags, nil
+}
@@ -0,0 +1,97 @@
+package plugins
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/netlify/netlify-commons/ntoml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveInputs(t *testing.T) {
+	m, err := ParseManifest([]byte(testManifest))
+	require.NoError(t, err)
+
+	resolved, diags := m.ResolveInputs("plugins[0].inputs", map[string]interface{}{
+		"output_path": "report.html",
+		"retries":     int64(5),
+	})
+	assert.Empty(t, diags)
+	assert.Equal(t, map[string]interface{}{
+		"output_path": "report.html",
+		"retries":     int64(5),
+		"formats":     []interface{}{"html"},
+	}, resolved)
+
+	resolved, diags = m.ResolveInputs("plugins[0].inputs", map[string]interface{}{
+		"retries": "five",
+		"ouput":   "report.html",
+	})
+	assert.Equal(t, map[string]interface{}{"formats": []interface{}{"html"}}, resolved)
+	assert.Equal(t, []ntoml.Diagnostic{
+		{
+			Severity: ntoml.SeverityError,
+			Path:     "plugins[0].inputs.ouput",
+			Message:  `plugin netlify-plugin-test has no input "ouput", it accepts "output_path", "retries", "formats", "options"`,
+		},
+		{
+			Severity: ntoml.SeverityError,
+			Path:     "plugins[0].inputs.retries",
+			Message:  `input "retries" of plugin netlify-plugin-test must be a number`,
+		},
+		{
+			Severity: ntoml.SeverityError,
+			Path:     "plugins[0].inputs",
+			Message:  `plugin netlify-plugin-test requires input "output_path"`,
+		},
+	}, diags)
+}
+
+func TestValidateFile(t *testing.T) {
+	dir := t.TempDir()
+	pluginDir := filepath.Join(dir, "plugins", "test")
+	require.NoError(t, os.MkdirAll(pluginDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(pluginDir, "manifest.yml"), []byte(testManifest), 0644))
+
+	config := `[build]
+  command = "make"
+
+[[plugins]]
+  package = "./plugins/test"
+  [plugins.inputs]
+    output_path = "report.html"
+    retries = "3"
+
+[[plugins]]
+  package = "netlify-plugin-missing"
+
+[context.production]
+  [[context.production.plugins]]
+    package = "./plugins/test"
+`
+	path := filepath.Join(dir, "netlify.toml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(config), 0644))
+
+	diags, err := ValidateFile(path)
+	require.NoError(t, err)
+	require.Len(t, diags, 3)
+
+	assert.Equal(t, path+`:8:5: error: plugins[0].inputs.retries: input "retries" of plugin netlify-plugin-test must be a number`, diags[0].String())
+	assert.Equal(t, ntoml.SeverityWarning, diags[1].Severity)
+	assert.Equal(t, 11, diags[1].Line)
+	assert.Equal(t, path+`:14:3: error: context.production.plugins[0].inputs: plugin netlify-plugin-test requires input "output_path"`, diags[2].String())
+}
+
+func TestValidateInvalidManifest(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "manifest.yml"), []byte("inputs:\n  - name: a\n  - name: a\n"), 0644))
+
+	diags := Validate(&ntoml.NetlifyToml{Plugins: []ntoml.Plugin{{Package: dir}}}, "/")
+	require.Len(t, diags, 1)
+	assert.Equal(t, ntoml.SeverityError, diags[0].Severity)
+	assert.Equal(t, "plugins[0].package", diags[0].Path)
+	assert.Contains(t, diags[0].Message, `input "a" is declared more than once`)
+}
@@ -0,0 +1,107 @@
+package ntoml
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Position is a location in a config file. Line and Column are 1-based.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// KeyPositions maps the key paths of a TOML document to the position where they are defined.
+// BurntSushi/toml doesn't record positions, so the document is scanned with the same line
+// scanner Document uses, which follows multi-line strings and arrays to their end. Paths
+// are stored both with array indexes (redirects[1].status) and without (redirects.status), the
+// latter pointing at the first occurrence.
+type KeyPositions map[string]Position
+
+// ScanKeyPositions returns the positions of the keys and tables in a TOML document.
+func ScanKeyPositions(data []byte) KeyPositions {
+	positions := make(KeyPositions)
+	arrays := make(map[string]int) // indexed path of an array of tables -> last index
+	var prefix, plainPrefix string
+
+	add := func(path, plain string, pos Position) {
+		positions[path] = pos
+		if _, ok := positions[plain]; !ok {
+			positions[plain] = pos
+		}
+	}
+
+	src := strings.Split(string(data), "\n")
+	for i, l := range scanLines(src) {
+		pos := Position{Line: i + 1, Column: len(leadingSpace(src[i])) + 1}
+		switch l.kind {
+		case lineTable, lineArrayTable:
+			prefix, plainPrefix = "", ""
+			for j, part := range l.key {
+				prefix = joinPath(prefix, part)
+				plainPrefix = joinPath(plainPrefix, part)
+				if j == len(l.key)-1 && l.kind == lineArrayTable {
+					arrays[prefix]++
+				}
+				if idx, ok := arrays[prefix]; ok {
+					prefix += "[" + strconv.Itoa(idx-1) + "]"
+				}
+			}
+			add(prefix, plainPrefix, pos)
+		case lineKey:
+			path, plain := prefix, plainPrefix
+			for _, part := range l.key {
+				path = joinPath(path, part)
+				plain = joinPath(plain, part)
+			}
+			add(path, plain, pos)
+		}
+	}
+	return positions
+}
+
+// Lookup returns the position of path, falling back to the closest parent that is known.
+func (kp KeyPositions) Lookup(path string) (Position, bool) {
+	for path != "" {
+		if pos, ok := kp[path]; ok {
+			return pos, true
+		}
+		idx := strings.LastIndexAny(path, ".[")
+		if idx == -1 {
+			break
+		}
+		path = path[:idx]
+	}
+	return Position{}, false
+}
+
+// splitKey splits a dotted TOML key, honoring quoted parts.
+func splitKey(key string) []string {
+	var parts []string
+	var cur strings.Builder
+	var quote byte
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteByte(c)
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '.':
+			parts = append(parts, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		case c != ' ' && c != '\t':
+			cur.WriteByte(c)
+		}
+	}
+	return append(parts, strings.TrimSpace(cur.String()))
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
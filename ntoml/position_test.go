@@ -0,0 +1,106 @@
+package ntoml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanKeyPositions(t *testing.T) {
+	tests := []struct {
+		name   string
+		doc    string
+		want   map[string]Position
+		absent []string
+	}{
+		{
+			name: "tables and arrays of tables",
+			doc: `[build]
+  publish = "dist"
+
+[[redirects]]
+  from = "/a"
+[[redirects]]
+  from = "/b"
+  status = 301
+`,
+			want: map[string]Position{
+				"build":               {Line: 1, Column: 1},
+				"build.publish":       {Line: 2, Column: 3},
+				"redirects[0].from":   {Line: 5, Column: 3},
+				"redirects[1]":        {Line: 6, Column: 1},
+				"redirects[1].status": {Line: 8, Column: 3},
+				"redirects.status":    {Line: 8, Column: 3},
+			},
+		},
+		{
+			name: "multi-line basic string",
+			doc: `[build]
+  command = """
+[skip]
+  publish = "nope"
+"""
+  publish = "dist"
+`,
+			want:   map[string]Position{"build.publish": {Line: 6, Column: 3}},
+			absent: []string{"skip", "skip.publish"},
+		},
+		{
+			name: "multi-line literal string with quotes",
+			doc: `[build]
+  command = '''
+echo "[x]" """
+a = b
+'''
+  publish = "dist"
+`,
+			want:   map[string]Position{"build.publish": {Line: 6, Column: 3}},
+			absent: []string{"x", "build.a"},
+		},
+		{
+			name: "nested arrays",
+			doc: `[build]
+  matrix = [
+    ["[a]", "b = c"],
+    [
+      "d",
+    ],
+  ]
+  publish = "dist"
+[functions]
+  included_files = ["a=b"]
+  directory = "fn"
+`,
+			want: map[string]Position{
+				"build.matrix":        {Line: 2, Column: 3},
+				"build.publish":       {Line: 8, Column: 3},
+				"functions":           {Line: 9, Column: 1},
+				"functions.directory": {Line: 11, Column: 3},
+			},
+			absent: []string{"a", "build.\"b", "build.[\"[a]\", \"b"},
+		},
+		{
+			name: "quoted keys with equals signs",
+			doc: `[headers.values]
+  "X-A=B" = "1"
+  X-C = "2"
+`,
+			want: map[string]Position{
+				"headers.values.X-A=B": {Line: 2, Column: 3},
+				"headers.values.X-C":   {Line: 3, Column: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			positions := ScanKeyPositions([]byte(tt.doc))
+			for path, want := range tt.want {
+				assert.Equal(t, want, positions[path], path)
+			}
+			for _, path := range tt.absent {
+				assert.NotContains(t, positions, path)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package ntoml
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultRedirectStatus is the status of a redirect rule that doesn't set one.
+const DefaultRedirectStatus = 301
+
+// RedirectRequest holds the request attributes redirect rules are matched against.
+type RedirectRequest struct {
+	// Host is only compared for rules whose from is an absolute URL
+	Host  string
+	Path  string
+	Query url.Values
+
+	// Languages, Country and Roles are compared against the rule conditions. Languages are
+	// ordered by preference, e.g. from the Accept-Language header.
+	Languages []string
+	Country   string
+	Roles     []string
+
+	// FileExists reports whether the site has a file at path. When it does, rules that aren't
+	// forced are shadowed by the file. A nil FileExists never shadows.
+	FileExists func(path string) bool
+}
+
+// RedirectMatch is the result of matching a request against the redirect rules.
+type RedirectMatch struct {
+	Rule        *Redirect
+	Destination string
+	Status      int
+	Params      map[string]string
+}
+
+// RedirectMatcher evaluates redirect rules in order, the first matching rule wins.
+type RedirectMatcher struct {
+	rules []compiledRedirect
+}
+
+type compiledRedirect struct {
+	rule     *Redirect
+	host     string
+	segments []string
+	splat    bool
+}
+
+var placeholderPattern = regexp.MustCompile(`:[A-Za-z_][A-Za-z0-9_]*`)
+
+// NewRedirectMatcher compiles the given rules.
+func NewRedirectMatcher(rules []Redirect) (*RedirectMatcher, error) {
+	m := &RedirectMatcher{rules: make([]compiledRedirect, 0, len(rules))}
+	for i := range rules {
+		cr, err := compileRedirect(&rules[i])
+		if err != nil {
+			return nil, fmt.Errorf("redirect %d: %w", i, err)
+		}
+		m.rules = append(m.rules, cr)
+	}
+	return m, nil
+}
+
+func compileRedirect(r *Redirect) (compiledRedirect, error) {
+	cr := compiledRedirect{rule: r}
+
+	from := r.Source()
+	if from == "" {
+		return cr, fmt.Errorf("missing from")
+	}
+	if strings.Contains(from, "://") {
+		u, err := url.Parse(from)
+		if err != nil {
+			return cr, fmt.Errorf("invalid from %q: %w", from, err)
+		}
+		cr.host = strings.ToLower(u.Host)
+		from = u.Path
+	}
+	if !strings.HasPrefix(from, "/") {
+		from = "/" + from
+	}
+
+	cr.segments = splitPath(from)
+	for i, seg := range cr.segments {
+		if seg != "*" {
+			continue
+		}
+		if i != len(cr.segments)-1 {
+			return cr, fmt.Errorf("splat must be the last segment of %q", r.Source())
+		}
+		cr.splat = true
+		cr.segments = cr.segments[:i]
+	}
+	return cr, nil
+}
+
+// Match returns the first rule matching the request, or nil when none does.
+func (m *RedirectMatcher) Match(req RedirectRequest) *RedirectMatch {
+	path := splitPath(req.Path)
+	shadowed := req.FileExists != nil && req.FileExists(req.Path)
+
+	for i := range m.rules {
+		cr := &m.rules[i]
+		if shadowed && !cr.rule.Force {
+			continue
+		}
+		if cr.host != "" && !strings.EqualFold(cr.host, req.Host) {
+			continue
+		}
+
+		params, ok := cr.matchPath(path)
+		if !ok || !matchQuery(cr.rule.Query(), req.Query, params) || !matchConditions(cr.rule.Conditions, req) {
+			continue
+		}
+
+		status := cr.rule.Status
+		if status == 0 {
+			status = DefaultRedirectStatus
+		}
+		return &RedirectMatch{
+			Rule:        cr.rule,
+			Destination: expandPlaceholders(cr.rule.Target(), params),
+			Status:      status,
+			Params:      params,
+		}
+	}
+	return nil
+}
+
+func (cr *compiledRedirect) matchPath(path []string) (map[string]string, bool) {
+	if len(path) < len(cr.segments) || (!cr.splat && len(path) != len(cr.segments)) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, seg := range cr.segments {
+		if strings.HasPrefix(seg, ":") {
+			params[seg[1:]] = path[i]
+		} else if seg != path[i] {
+			return nil, false
+		}
+	}
+	if cr.splat {
+		params["splat"] = strings.Join(path[len(cr.segments):], "/")
+	}
+	return params, true
+}
+
+// matchQuery requires every parameter of the rule to be present. Values starting with a colon
+// are placeholders that capture the request value, others must match exactly.
+func matchQuery(rule map[string]string, query url.Values, params map[string]string) bool {
+	for key, value := range rule {
+		got, ok := query[key]
+		if !ok || len(got) == 0 {
+			return false
+		}
+		if strings.HasPrefix(value, ":") {
+			params[value[1:]] = got[0]
+		} else if got[0] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func matchConditions(c *RedirectCondition, req RedirectRequest) bool {
+	if c == nil {
+		return true
+	}
+	if len(c.Language) > 0 && !matchLanguage(c.Language, req.Languages) {
+		return false
+	}
+	if len(c.Country) > 0 && !containsFold(c.Country, req.Country) {
+		return false
+	}
+	if len(c.Role) > 0 {
+		found := false
+		for _, role := range req.Roles {
+			if containsFold(c.Role, role) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLanguage matches on the primary language subtag, so "en" matches "en-US".
+func matchLanguage(allowed, languages []string) bool {
+	for _, lang := range languages {
+		primary := strings.SplitN(lang, "-", 2)[0]
+		for _, a := range allowed {
+			if strings.EqualFold(a, lang) || strings.EqualFold(a, primary) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, el := range list {
+		if strings.EqualFold(el, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func expandPlaceholders(dest string, params map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(dest, func(p string) string {
+		if v, ok := params[p[1:]]; ok {
+			return v
+		}
+		return p
+	})
+}
+
+// splitPath splits a path into its segments, ignoring a trailing slash.
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
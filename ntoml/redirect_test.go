@@ -0,0 +1,82 @@
+package ntoml
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectMatcher(t *testing.T) {
+	rules := []Redirect{
+		{From: "/news/:year/:slug", To: "/blog/:year/:slug.html"},
+		{From: "/store", To: "/shop/:id", Status: 302, QueryParams: map[string]string{"id": ":id"}},
+		{From: "/legacy", To: "/exact", QueryParams: map[string]string{"v": "1"}},
+		{From: "/fr/*", To: "/fr/index.html", Status: 200, Conditions: &RedirectCondition{Language: []string{"fr"}}},
+		{From: "/us-only", To: "/us.html", Status: 200, Conditions: &RedirectCondition{Country: []string{"US"}}},
+		{From: "/admin/*", To: "/admin/:splat", Status: 200, Conditions: &RedirectCondition{Role: []string{"admin"}}},
+		{From: "/app/*", To: "/index.html", Status: 200},
+		{Origin: "/old/*", Destination: "/new/:splat"},
+		{From: "https://old.example.com/*", To: "https://example.com/:splat", Status: 301, Force: true},
+		{From: "/forced", To: "/elsewhere", Force: true},
+		{From: "/*", To: "/404.html", Status: 404},
+	}
+	m, err := NewRedirectMatcher(rules)
+	require.NoError(t, err)
+
+	exists := func(paths ...string) func(string) bool {
+		return func(p string) bool {
+			for _, e := range paths {
+				if e == p {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	tests := []struct {
+		name   string
+		req    RedirectRequest
+		dest   string
+		status int
+	}{
+		{"named placeholders", RedirectRequest{Path: "/news/2004/hello"}, "/blog/2004/hello.html", 301},
+		{"trailing slash", RedirectRequest{Path: "/news/2004/hello/"}, "/blog/2004/hello.html", 301},
+		{"query placeholder", RedirectRequest{Path: "/store", Query: url.Values{"id": {"42"}}}, "/shop/42", 302},
+		{"query literal", RedirectRequest{Path: "/legacy", Query: url.Values{"v": {"1"}}}, "/exact", 301},
+		{"query literal mismatch", RedirectRequest{Path: "/legacy", Query: url.Values{"v": {"2"}}}, "/404.html", 404},
+		{"language", RedirectRequest{Path: "/fr/about", Languages: []string{"fr-CA", "en"}}, "/fr/index.html", 200},
+		{"language mismatch", RedirectRequest{Path: "/fr/about", Languages: []string{"de"}}, "/404.html", 404},
+		{"country", RedirectRequest{Path: "/us-only", Country: "us"}, "/us.html", 200},
+		{"role", RedirectRequest{Path: "/admin/users/1", Roles: []string{"editor", "admin"}}, "/admin/users/1", 200},
+		{"role mismatch", RedirectRequest{Path: "/admin/users/1", Roles: []string{"editor"}}, "/404.html", 404},
+		{"splat rewrite", RedirectRequest{Path: "/app/some/route"}, "/index.html", 200},
+		{"legacy fields", RedirectRequest{Path: "/old/a/b"}, "/new/a/b", 301},
+		{"domain redirect", RedirectRequest{Host: "OLD.example.com", Path: "/docs/x"}, "https://example.com/docs/x", 301},
+		{"shadowed by file", RedirectRequest{Path: "/app/main.js", FileExists: exists("/app/main.js")}, "", 0},
+		{"forced despite file", RedirectRequest{Path: "/forced", FileExists: exists("/forced")}, "/elsewhere", 301},
+		{"not found fallback", RedirectRequest{Path: "/missing"}, "/404.html", 404},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			match := m.Match(tc.req)
+			if tc.status == 0 {
+				assert.Nil(t, match)
+				return
+			}
+			require.NotNil(t, match)
+			assert.Equal(t, tc.dest, match.Destination)
+			assert.Equal(t, tc.status, match.Status)
+		})
+	}
+}
+
+func TestRedirectMatcherInvalidRules(t *testing.T) {
+	_, err := NewRedirectMatcher([]Redirect{{To: "/a"}})
+	assert.Error(t, err)
+
+	_, err = NewRedirectMatcher([]Redirect{{From: "/a/*/b", To: "/a"}})
+	assert.Error(t, err)
+}
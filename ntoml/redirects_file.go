@@ -0,0 +1,207 @@
+package ntoml
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	RedirectsFilename = "_redirects"
+	HeadersFilename   = "_headers"
+)
+
+// ParseError is returned when a _redirects or _headers file can't be parsed.
+type ParseError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// LoadRedirectsFile parses the _redirects file at path.
+func LoadRedirectsFile(path string) ([]Redirect, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules, err := ParseRedirects(f)
+	if perr, ok := err.(*ParseError); ok {
+		perr.File = path
+	}
+	return rules, err
+}
+
+// ParseRedirects parses rules in the _redirects format, one rule per line:
+//
+//	from [query params] to [status[!]] [conditions]
+//
+// e.g. `/store id=:id /blog/:id 301!` or `/ /china 302 Country=cn,hk`. A trailing ! forces the
+// rule.
+func ParseRedirects(r io.Reader) ([]Redirect, error) {
+	var rules []Redirect
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, err := parseRedirectLine(text)
+		if err != nil {
+			return nil, &ParseError{Line: line, Msg: err.Error()}
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func parseRedirectLine(line string) (Redirect, error) {
+	// trailing comments are allowed after the rule
+	if idx := strings.Index(line, " #"); idx != -1 {
+		line = line[:idx]
+	}
+	fields := strings.Fields(line)
+
+	r := Redirect{From: fields[0]}
+	i := 1
+	for ; i < len(fields) && !isRedirectTarget(fields[i]) && !isRedirectStatus(fields[i]); i++ {
+		parts := strings.SplitN(fields[i], "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return r, fmt.Errorf("invalid query parameter %q", fields[i])
+		}
+		if r.QueryParams == nil {
+			r.QueryParams = make(map[string]string)
+		}
+		r.QueryParams[parts[0]] = parts[1]
+	}
+
+	if i == len(fields) || !isRedirectTarget(fields[i]) {
+		return r, fmt.Errorf("missing destination for %q", r.From)
+	}
+	r.To = fields[i]
+	i++
+
+	if i < len(fields) && isRedirectStatus(fields[i]) {
+		status := fields[i]
+		if strings.HasSuffix(status, "!") {
+			r.Force = true
+			status = strings.TrimSuffix(status, "!")
+		}
+		r.Status, _ = strconv.Atoi(status)
+		i++
+	}
+
+	for ; i < len(fields); i++ {
+		parts := strings.SplitN(fields[i], "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return r, fmt.Errorf("invalid condition %q", fields[i])
+		}
+		values := strings.Split(parts[1], ",")
+		if r.Conditions == nil {
+			r.Conditions = new(RedirectCondition)
+		}
+		switch strings.ToLower(parts[0]) {
+		case "language":
+			r.Conditions.Language = values
+		case "country":
+			r.Conditions.Country = values
+		case "role":
+			r.Conditions.Role = values
+		default:
+			return r, fmt.Errorf("unknown condition %q", parts[0])
+		}
+	}
+	return r, nil
+}
+
+func isRedirectTarget(s string) bool {
+	return strings.HasPrefix(s, "/") || strings.Contains(s, "://")
+}
+
+func isRedirectStatus(s string) bool {
+	s = strings.TrimSuffix(s, "!")
+	if len(s) != 3 {
+		return false
+	}
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// WriteRedirects writes rules in the _redirects format. Rules using features the format can't
+// express, like custom headers or signing, are rejected.
+func WriteRedirects(w io.Writer, rules []Redirect) error {
+	bw := bufio.NewWriter(w)
+	for i := range rules {
+		r := &rules[i]
+		if len(r.Headers) > 0 || r.Signed != "" {
+			return fmt.Errorf("redirect %d from %s uses headers or signed, which _redirects can't express", i, r.Source())
+		}
+
+		parts := []string{r.Source()}
+		query := r.Query()
+		for _, k := range sortedKeys(query) {
+			parts = append(parts, k+"="+query[k])
+		}
+		parts = append(parts, r.Target())
+
+		status := r.Status
+		if status == 0 && r.Force {
+			status = DefaultRedirectStatus
+		}
+		if status != 0 {
+			s := strconv.Itoa(status)
+			if r.Force {
+				s += "!"
+			}
+			parts = append(parts, s)
+		}
+
+		if c := r.Conditions; c != nil {
+			for _, cond := range []struct {
+				name   string
+				values []string
+			}{{"Language", c.Language}, {"Country", c.Country}, {"Role", c.Role}} {
+				if len(cond.values) > 0 {
+					parts = append(parts, cond.name+"="+strings.Join(cond.values, ","))
+				}
+			}
+		}
+
+		if _, err := bw.WriteString(strings.Join(parts, " ") + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// MergeRedirects combines the rules of a _redirects file with the ones from netlify.toml. Rules
+// from the _redirects file are evaluated first.
+func MergeRedirects(fileRules, configRules []Redirect) []Redirect {
+	out := make([]Redirect, 0, len(fileRules)+len(configRules))
+	out = append(out, fileRules...)
+	return append(out, configRules...)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,83 @@
+package ntoml
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const redirectsFile = `# comment
+/home              /
+/blog/*            /news/:splat  301!
+/store id=:id      /blog/:id     302
+
+/  /china 302  Country=cn,hk,tw
+/admin/*  /admin/*  200!  Role=admin
+/news  https://example.com/news  Language=en  # trailing comment
+`
+
+func TestParseRedirects(t *testing.T) {
+	rules, err := ParseRedirects(strings.NewReader(redirectsFile))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Redirect{
+		{From: "/home", To: "/"},
+		{From: "/blog/*", To: "/news/:splat", Status: 301, Force: true},
+		{From: "/store", To: "/blog/:id", Status: 302, QueryParams: map[string]string{"id": ":id"}},
+		{From: "/", To: "/china", Status: 302, Conditions: &RedirectCondition{Country: []string{"cn", "hk", "tw"}}},
+		{From: "/admin/*", To: "/admin/*", Status: 200, Force: true, Conditions: &RedirectCondition{Role: []string{"admin"}}},
+		{From: "/news", To: "https://example.com/news", Conditions: &RedirectCondition{Language: []string{"en"}}},
+	}, rules)
+}
+
+func TestParseRedirectsErrors(t *testing.T) {
+	_, err := ParseRedirects(strings.NewReader("/a /b\n\n/c /d 301 Planet=mars\n"))
+	require.Error(t, err)
+	perr, ok := err.(*ParseError)
+	require.True(t, ok)
+	assert.Equal(t, 3, perr.Line)
+	assert.Equal(t, `line 3: unknown condition "Planet"`, err.Error())
+
+	path := filepath.Join(t.TempDir(), RedirectsFilename)
+	require.NoError(t, ioutil.WriteFile(path, []byte("/a bogus /b\n"), 0644))
+	_, err = LoadRedirectsFile(path)
+	require.Error(t, err)
+	assert.Equal(t, path+`:1: invalid query parameter "bogus"`, err.Error())
+
+	_, err = ParseRedirects(strings.NewReader("/a /b\n/a 301\n"))
+	require.Error(t, err)
+	assert.Equal(t, `line 2: missing destination for "/a"`, err.Error())
+}
+
+func TestWriteRedirectsRoundTrip(t *testing.T) {
+	rules, err := ParseRedirects(strings.NewReader(redirectsFile))
+	require.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, WriteRedirects(buf, rules))
+
+	reparsed, err := ParseRedirects(buf)
+	require.NoError(t, err)
+	assert.Equal(t, rules, reparsed)
+
+	// legacy toml fields are converted, headers can't be expressed
+	buf.Reset()
+	require.NoError(t, WriteRedirects(buf, []Redirect{{Origin: "/a", Destination: "/b", Force: true}}))
+	assert.Equal(t, "/a /b 301!\n", buf.String())
+	require.Error(t, WriteRedirects(buf, []Redirect{{From: "/a", To: "/b", Headers: map[string]string{"X": "y"}}}))
+}
+
+func TestMergeRedirects(t *testing.T) {
+	merged := MergeRedirects(
+		[]Redirect{{From: "/file"}},
+		[]Redirect{{From: "/toml"}},
+	)
+	require.Len(t, merged, 2)
+	assert.Equal(t, "/file", merged[0].From)
+	assert.Equal(t, "/toml", merged[1].From)
+}
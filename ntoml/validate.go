@@ -0,0 +1,370 @@
+package ntoml
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Diagnostic describes a problem with a config. Path is the key path of the offending value,
+// e.g. redirects[0].status. File, Line and Column are only set by ValidateFile.
+type Diagnostic struct {
+	Severity Severity
+	Path     string
+	Message  string
+
+	File   string
+	Line   int
+	Column int
+}
+
+func (d Diagnostic) String() string {
+	var loc string
+	switch {
+	case d.File != "" && d.Line > 0:
+		loc = fmt.Sprintf("%s:%d:%d: ", d.File, d.Line, d.Column)
+	case d.Line > 0:
+		loc = fmt.Sprintf("%d:%d: ", d.Line, d.Column)
+	case d.File != "":
+		loc = d.File + ": "
+	}
+	return fmt.Sprintf("%s%s: %s: %s", loc, d.Severity, d.Path, d.Message)
+}
+
+// HasErrors reports whether any of the diagnostics is an error.
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+var validRedirectStatus = map[int]bool{
+	200: true, 301: true, 302: true, 303: true, 307: true, 308: true, 404: true, 410: true, 451: true,
+}
+
+// Validate checks a config for semantic problems that decoding doesn't catch.
+func Validate(conf *NetlifyToml) []Diagnostic {
+	v := new(validator)
+
+	v.redirects("redirects", conf.Redirects)
+	for i, h := range conf.Headers {
+		p := fmt.Sprintf("headers[%d]", i)
+		if h.For == "" {
+			v.errorf(p+".for", "header rule needs a path to apply to")
+		}
+		if len(h.Values) == 0 {
+			v.warnf(p+".values", "header rule for %q sets no headers", h.For)
+		}
+	}
+	if conf.Build != nil {
+		v.build("build", conf.Build)
+	}
+	v.plugins("plugins", conf.Plugins)
+	for i, ef := range conf.EdgeFunctions {
+		p := fmt.Sprintf("edge_functions[%d]", i)
+		if ef.Function == "" {
+			v.errorf(p+".function", "edge function declaration needs a function name")
+		}
+		if ef.Path != "" && !strings.HasPrefix(ef.Path, "/") {
+			v.errorf(p+".path", "path %q must start with /", ef.Path)
+		}
+	}
+
+	names := make([]string, 0, len(conf.Context))
+	for name := range conf.Context {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	seen := make(map[string]string)
+	for _, name := range names {
+		p := "context." + name
+		if other, ok := seen[strings.ToLower(name)]; ok {
+			v.warnf(p, "context %q only differs in case from context %q, they apply to different branches", other, name)
+		}
+		seen[strings.ToLower(name)] = name
+		for _, reserved := range []string{ContextProduction, ContextDeployPreview, ContextBranchDeploy} {
+			if name != reserved && strings.EqualFold(name, reserved) {
+				v.warnf(p, "context %q only applies to a branch with that name, did you mean %q?", name, reserved)
+			}
+		}
+
+		dc := conf.Context[name]
+		base := dc.BuildConfig
+		if base.Base == "" && conf.Build != nil {
+			base.Base = conf.Build.Base
+		}
+		v.build(p, &base)
+		v.plugins(p+".plugins", dc.Plugins)
+		v.redirects(p+".redirects", dc.Redirects)
+	}
+
+	return v.diags
+}
+
+// ValidateFile decodes and validates the config at path. For TOML files it also reports
+// unknown keys, and every diagnostic carries the position of the offending key. The returned
+// error is only set when the file can't be read or decoded.
+func ValidateFile(path string) (*NetlifyToml, []Diagnostic, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Error while reading in file %s", path)
+	}
+
+	conf := new(NetlifyToml)
+	if format := FormatOf(path); format != FormatTOML {
+		if err := Decode(data, format, conf); err != nil {
+			return nil, nil, errors.Wrapf(err, "Error while decoding file %s", path)
+		}
+		diags := Validate(conf)
+		for i := range diags {
+			diags[i].File = path
+		}
+		return conf, diags, nil
+	}
+
+	md, err := toml.Decode(string(data), conf)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Error while decoding file %s", path)
+	}
+
+	diags := append(unknownKeys(md), Validate(conf)...)
+	positions := ScanKeyPositions(data)
+	for i := range diags {
+		diags[i].File = path
+		if pos, ok := positions.Lookup(diags[i].Path); ok {
+			diags[i].Line = pos.Line
+			diags[i].Column = pos.Column
+		}
+	}
+	sort.SliceStable(diags, func(i, j int) bool { return diags[i].Line < diags[j].Line })
+	return conf, diags, nil
+}
+
+func unknownKeys(md toml.MetaData) []Diagnostic {
+	var diags []Diagnostic
+	reported := make(map[string]bool)
+	for _, key := range md.Undecoded() {
+		// these hold free-form tables that are decoded without tracking their keys
+		if key[0] == "functions" || containsString(key, "inputs") {
+			continue
+		}
+		// only report the outermost unknown key, not everything nested under it
+		if len(key) > 1 && reported[key[:len(key)-1].String()] {
+			reported[key.String()] = true
+			continue
+		}
+		reported[key.String()] = true
+		msg := fmt.Sprintf("unknown key %q", key[len(key)-1])
+		if suggestion := suggestKey(key); suggestion != "" {
+			msg += fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+		diags = append(diags, Diagnostic{Severity: SeverityWarning, Path: key.String(), Message: msg})
+	}
+	return diags
+}
+
+// suggestKey returns the known key closest to the last part of key, if there is a close one.
+func suggestKey(key toml.Key) string {
+	t := reflect.TypeOf(NetlifyToml{})
+	for _, part := range key[:len(key)-1] {
+		t = fieldType(t, part)
+		if t == nil {
+			return ""
+		}
+	}
+
+	best, bestDist := "", 3
+	for _, name := range tomlNames(t) {
+		if d := levenshtein(strings.ToLower(key[len(key)-1]), name); d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// fieldType returns the type a key selects in t, looking through pointers, slices and maps.
+func fieldType(t reflect.Type, key string) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem()
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous {
+				if ft := fieldType(f.Type, key); ft != nil {
+					return ft
+				}
+				continue
+			}
+			if strings.EqualFold(tomlName(f), key) {
+				return f.Type
+			}
+		}
+	}
+	return nil
+}
+
+func tomlNames(t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			names = append(names, tomlNames(f.Type)...)
+		} else if f.PkgPath == "" {
+			names = append(names, tomlName(f))
+		}
+	}
+	return names
+}
+
+func tomlName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("toml"), ",")[0]; name != "" {
+		return name
+	}
+	return f.Name
+}
+
+type validator struct {
+	diags []Diagnostic
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.diags = append(v.diags, Diagnostic{Severity: SeverityError, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(path, format string, args ...interface{}) {
+	v.diags = append(v.diags, Diagnostic{Severity: SeverityWarning, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) redirects(prefix string, rules []Redirect) {
+	for i := range rules {
+		r := &rules[i]
+		p := prefix + "[" + strconv.Itoa(i) + "]"
+
+		fromKey, toKey := p+".from", p+".to"
+		if r.From == "" && r.Origin != "" {
+			fromKey = p + ".origin"
+		}
+		if r.To == "" && r.Destination != "" {
+			toKey = p + ".destination"
+		}
+
+		if from := r.Source(); from == "" {
+			v.errorf(fromKey, "redirect needs a path to match on")
+		} else if !strings.HasPrefix(from, "/") && !strings.Contains(from, "://") {
+			v.errorf(fromKey, "%q must start with / or be an absolute URL", from)
+		} else if _, err := compileRedirect(r); err != nil {
+			v.errorf(fromKey, "%s", err)
+		}
+
+		if to := r.Target(); to == "" {
+			v.errorf(toKey, "redirect needs a destination")
+		} else if !strings.HasPrefix(to, "/") && !strings.Contains(to, "://") {
+			v.errorf(toKey, "%q must start with / or be an absolute URL", to)
+		}
+
+		if r.Status != 0 && !validRedirectStatus[r.Status] {
+			v.errorf(p+".status", "invalid redirect status %d", r.Status)
+		}
+		if (r.From != "" && r.Origin != "") || (r.To != "" && r.Destination != "") {
+			v.warnf(p, "both from/to and the legacy origin/destination are set, from/to take precedence")
+		}
+	}
+}
+
+func (v *validator) build(prefix string, b *BuildConfig) {
+	if b.Publish == "" || path.IsAbs(b.Publish) {
+		return
+	}
+	if publish := path.Clean(b.Publish); publish == ".." || strings.HasPrefix(publish, "../") {
+		base := b.Base
+		if base == "" {
+			base = "."
+		}
+		v.errorf(prefix+".publish", "publish directory %q is outside the base directory %q", b.Publish, base)
+	}
+}
+
+func (v *validator) plugins(prefix string, plugins []Plugin) {
+	seen := make(map[string]bool)
+	for i, p := range plugins {
+		key := prefix + "[" + strconv.Itoa(i) + "]"
+		if p.Package == "" {
+			v.errorf(key+".package", "plugin needs a package")
+			continue
+		}
+		if seen[p.Package] {
+			v.errorf(key+".package", "plugin %q is declared more than once", p.Package)
+		}
+		seen[p.Package] = true
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, el := range list {
+		if el == s {
+			return true
+		}
+	}
+	return false
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, minInt(cur[j-1]+1, prev[j-1]+cost))
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,118 @@
+package ntoml
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	conf := &NetlifyToml{
+		Redirects: []Redirect{
+			{From: "/a", To: "/b"},
+			{From: "/a", To: "b", Status: 201},
+			{From: "/a/*/b", To: "/c"},
+		},
+		Headers: []Header{{Values: map[string]string{"X-Frame-Options": "DENY"}}},
+		Build:   &BuildConfig{Base: "site", Publish: "../dist"},
+		Plugins: []Plugin{{Package: "a"}, {Package: "a"}},
+		Context: map[string]DeployContext{
+			"Production": {},
+			"Feature":    {},
+			"feature":    {},
+		},
+		EdgeFunctions: []EdgeFunction{{Function: "geo", Path: "geo"}},
+	}
+
+	var got []string
+	for _, d := range Validate(conf) {
+		got = append(got, d.String())
+	}
+	assert.ElementsMatch(t, []string{
+		`error: redirects[1].to: "b" must start with / or be an absolute URL`,
+		`error: redirects[1].status: invalid redirect status 201`,
+		`error: redirects[2].from: splat must be the last segment of "/a/*/b"`,
+		`error: headers[0].for: header rule needs a path to apply to`,
+		`error: build.publish: publish directory "../dist" is outside the base directory "site"`,
+		`error: plugins[1].package: plugin "a" is declared more than once`,
+		`error: edge_functions[0].path: path "geo" must start with /`,
+		`warning: context.Production: context "Production" only applies to a branch with that name, did you mean "production"?`,
+		`warning: context.feature: context "Feature" only differs in case from context "feature", they apply to different branches`,
+	}, got)
+}
+
+func TestValidateValidConfig(t *testing.T) {
+	conf := &NetlifyToml{
+		Redirects: []Redirect{{From: "/*", To: "/index.html", Status: 200}},
+		Build:     &BuildConfig{Base: "site", Publish: "dist"},
+		Context: map[string]DeployContext{
+			ContextProduction: {BuildConfig: BuildConfig{Publish: "build"}},
+			"staging":         {},
+		},
+	}
+	assert.Empty(t, Validate(conf))
+}
+
+func TestValidateFile(t *testing.T) {
+	conf := `[build]
+  publish = "dist"
+  comand = "make"
+
+[[redirect]]
+  from = "/old"
+  to = "/new"
+
+[[redirects]]
+  from = "/a"
+  to = "/b"
+
+[[redirects]]
+  from = "/c"
+  to = "/d"
+  status = 999
+`
+	dir, err := ioutil.TempDir("", "ntoml")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "netlify.toml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(conf), 0644))
+
+	parsed, diags, err := ValidateFile(path)
+	require.NoError(t, err)
+	require.Len(t, parsed.Redirects, 2)
+	require.True(t, HasErrors(diags))
+
+	require.Len(t, diags, 3)
+	assert.Equal(t, Diagnostic{
+		Severity: SeverityWarning,
+		Path:     "build.comand",
+		Message:  `unknown key "comand", did you mean "command"?`,
+		File:     path,
+		Line:     3,
+		Column:   3,
+	}, diags[0])
+	assert.Equal(t, Diagnostic{
+		Severity: SeverityWarning,
+		Path:     "redirect",
+		Message:  `unknown key "redirect", did you mean "redirects"?`,
+		File:     path,
+		Line:     5,
+		Column:   1,
+	}, diags[1])
+	assert.Equal(t, path+":16:3: error: redirects[1].status: invalid redirect status 999", diags[2].String())
+}
+
+func TestValidateFileSyntaxError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ntoml")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "netlify.toml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("[build\n"), 0644))
+
+	_, _, err = ValidateFile(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,11 @@
+package trafficmesh
+
+// Signing algorithms supported for traffic-mesh signatures.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+
+	// algorithmNone is never accepted, even when passed to WithAlgorithms.
+	algorithmNone = "none"
+)
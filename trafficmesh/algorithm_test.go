@@ -0,0 +1,110 @@
+package trafficmesh
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsymmetricSignatures(t *testing.T) {
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		alg  string
+		pub  interface{}
+		priv interface{}
+	}{
+		{"ed25519", AlgorithmEdDSA, edPub, edPriv},
+		{"es256", AlgorithmES256, &ecPriv.PublicKey, ecPriv},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			producer, err := NewKeyring(&KeyringConfig{
+				SigningKey:  "k1",
+				PrivateKeys: map[string]string{"k1": pemPrivateKey(t, test.priv)},
+			})
+			require.NoError(t, err)
+			consumer, err := NewKeyring(&KeyringConfig{
+				PublicKeys: map[string]string{"k1": pemPublicKey(t, test.pub)},
+			})
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.org/", nil)
+			_, err = NewSignatureEncoderWithKeyring(producer, 0).SignRequest(req, "1", "2", "3", false)
+			require.NoError(t, err)
+
+			payload, err := NewSignatureDecoderWithKeyring(consumer, WithAlgorithms(test.alg)).DecodeSignature(req)
+			require.NoError(t, err)
+			assert.Equal(t, "1", payload.SiteID)
+
+			// the default allow-list only accepts HS256
+			_, err = NewSignatureDecoderWithKeyring(consumer).DecodeSignature(req)
+			require.Error(t, err)
+
+			// a public key can't be used to sign
+			_, err = NewSignatureEncoderWithKeyring(consumer, 0).SignRequest(req, "1", "2", "3", false)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestAlgorithmConfusion(t *testing.T) {
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pubPEM := pemPublicKey(t, &ecPriv.PublicKey)
+
+	consumer, err := NewKeyring(&KeyringConfig{
+		PublicKeys: map[string]string{"k1": pubPEM},
+	})
+	require.NoError(t, err)
+	dec := NewSignatureDecoderWithKeyring(consumer, WithAlgorithms(AlgorithmHS256, AlgorithmES256))
+
+	// an HS256 token signed with the public key as the secret must not verify
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"url": "http://example.org/"})
+	token.Header[keyIDHeader] = "k1"
+	sig, err := token.SignedString([]byte(pubPEM))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.org/", nil)
+	req.Header.Set(signatureHeader, sig)
+	_, err = dec.DecodeSignature(req)
+	require.Error(t, err)
+}
+
+func TestKeyringRejectsUnsupportedCurves(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = NewKeyring(&KeyringConfig{PublicKeys: map[string]string{"k": pemPublicKey(t, &priv.PublicKey)}})
+	require.Error(t, err)
+	_, err = NewKeyring(&KeyringConfig{PrivateKeys: map[string]string{"k": pemPrivateKey(t, priv)}})
+	require.Error(t, err)
+	_, err = NewKeyring(&KeyringConfig{PublicKeys: map[string]string{"k": "not pem"}})
+	require.Error(t, err)
+}
+
+func pemPublicKey(t *testing.T, pub interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func pemPrivateKey(t *testing.T, priv interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
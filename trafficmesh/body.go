@@ -0,0 +1,236 @@
+package trafficmesh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrBodyDigestMismatch is returned by DecodeSignature when the request body doesn't match the
+// digest in its traffic-mesh signature.
+var ErrBodyDigestMismatch = errors.New("request body doesn't match the traffic mesh signature")
+
+// ErrBindingMismatch is returned by DecodeSignature when the request method or digest header
+// doesn't match the signature.
+var ErrBindingMismatch = errors.New("request doesn't match the traffic mesh signature binding")
+
+// DefaultMaxBodySize is the largest bound request body a decoder reads, see WithMaxBodySize.
+const DefaultMaxBodySize = 32 << 20
+
+// BodyTooLargeError is returned by DecodeSignature when a bound request body is larger than the
+// decoder's maximum body size.
+type BodyTooLargeError struct {
+	Limit int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("request body is larger than %d bytes", e.Limit)
+}
+
+// maxMemoryBody is the size up to which bound request bodies are kept in memory while their
+// digest is verified. Larger bodies are spooled to a temporary file.
+const maxMemoryBody = 1 << 20
+
+// WithBodyBinding makes SignRequest bind the token to the request method and a SHA-256 digest
+// of the body. The digest is taken from a Content-Digest or Digest header when the request has
+// one, otherwise the body is hashed. Bodies without GetBody are buffered to do so.
+func WithBodyBinding() EncoderOption {
+	return func(e *SignatureEncoder) {
+		e.bindBody = true
+	}
+}
+
+// WithMaxBodySize sets the largest request body that is read to verify a body binding. Larger
+// bodies are rejected with a *BodyTooLargeError before their digest is checked. It defaults to
+// DefaultMaxBodySize.
+func WithMaxBodySize(n int64) DecoderOption {
+	return func(d *SignatureDecoder) {
+		d.maxBodySize = n
+	}
+}
+
+// RequireBodyBinding rejects tokens that aren't bound to the request method and body. Tokens
+// that carry a binding are always verified, even without this option.
+func RequireBodyBinding() DecoderOption {
+	return func(d *SignatureDecoder) {
+		d.requireBinding = true
+	}
+}
+
+func (e *SignatureEncoder) bind(req *http.Request, payload *SignaturePayload) error {
+	payload.Method = req.Method
+	if digest, ok := digestFromHeaders(req.Header); ok {
+		payload.BodyDigest = digest
+		return nil
+	}
+
+	h := sha256.New()
+	switch {
+	case req.Body == nil || req.Body == http.NoBody:
+	case req.GetBody != nil:
+		body, err := req.GetBody()
+		if err != nil {
+			return fmt.Errorf("failed to get request body: %w", err)
+		}
+		defer body.Close()
+		if _, err := io.Copy(h, body); err != nil {
+			return fmt.Errorf("failed to hash request body: %w", err)
+		}
+	default:
+		buf := new(bytes.Buffer)
+		if _, err := io.Copy(io.MultiWriter(h, buf), req.Body); err != nil {
+			return fmt.Errorf("failed to hash request body: %w", err)
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(buf)
+	}
+	payload.BodyDigest = base64.StdEncoding.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// checkBinding verifies the method and digest header of the request and the digest of its
+// body. The body is read in full and req.Body is replaced with a copy, so handlers only ever see
+// a body that has been verified. A copy spooled to a temporary file is removed once it's read to
+// the end or closed.
+func (d *SignatureDecoder) checkBinding(req *http.Request, payload *SignaturePayload) error {
+	if payload.Method == "" && payload.BodyDigest == "" {
+		if d.requireBinding {
+			return fmt.Errorf("%w: mth, bdg", ErrMissingClaim)
+		}
+		return nil
+	}
+	if payload.Method == "" || payload.BodyDigest == "" {
+		return fmt.Errorf("%w: mth, bdg", ErrMissingClaim)
+	}
+
+	if payload.Method != req.Method {
+		return fmt.Errorf("%w: token method %s, request method %s", ErrBindingMismatch, payload.Method, req.Method)
+	}
+	expected, err := base64.StdEncoding.DecodeString(payload.BodyDigest)
+	if err != nil || len(expected) != sha256.Size {
+		return fmt.Errorf("%w: invalid body digest", ErrBindingMismatch)
+	}
+	if digest, ok := digestFromHeaders(req.Header); ok && digest != payload.BodyDigest {
+		return fmt.Errorf("%w: digest header doesn't match", ErrBindingMismatch)
+	}
+
+	if req.ContentLength > d.maxBodySize {
+		return &BodyTooLargeError{Limit: d.maxBodySize}
+	}
+	h := sha256.New()
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := copyBody(req.Body, h, d.maxBodySize)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		req.Body = body
+	}
+	if subtle.ConstantTimeCompare(h.Sum(nil), expected) != 1 {
+		return ErrBodyDigestMismatch
+	}
+	return nil
+}
+
+// copyBody reads body in full while writing it to h, failing once it's larger than limit.
+// Bodies up to maxMemoryBody are kept in memory, larger ones are spooled to a temporary file.
+func copyBody(body io.Reader, h hash.Hash, limit int64) (io.ReadCloser, error) {
+	body = &limitedReader{r: body, limit: limit}
+	buf := new(bytes.Buffer)
+	n, err := io.Copy(io.MultiWriter(h, buf), io.LimitReader(body, maxMemoryBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if n <= maxMemoryBody {
+		return ioutil.NopCloser(buf), nil
+	}
+
+	f, err := ioutil.TempFile("", "trafficmesh-body-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to spool request body: %w", err)
+	}
+	spool := &spooledBody{file: f}
+	if _, err := buf.WriteTo(f); err != nil {
+		spool.Close()
+		return nil, fmt.Errorf("failed to spool request body: %w", err)
+	}
+	if _, err := io.Copy(io.MultiWriter(h, f), body); err != nil {
+		spool.Close()
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		spool.Close()
+		return nil, fmt.Errorf("failed to spool request body: %w", err)
+	}
+	return spool, nil
+}
+
+// limitedReader fails with a *BodyTooLargeError once more than limit bytes are read.
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	n     int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		return n, &BodyTooLargeError{Limit: l.limit}
+	}
+	return n, err
+}
+
+// spooledBody is a request body spooled to a temporary file. The file is removed once it has
+// been read to the end or the body is closed, whichever comes first.
+type spooledBody struct {
+	file *os.File
+}
+
+func (b *spooledBody) Read(p []byte) (int, error) {
+	if b.file == nil {
+		return 0, io.EOF
+	}
+	n, err := b.file.Read(p)
+	if err == io.EOF {
+		b.Close()
+	}
+	return n, err
+}
+
+func (b *spooledBody) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	os.Remove(b.file.Name())
+	b.file = nil
+	return err
+}
+
+// digestFromHeaders returns the base64 encoded SHA-256 digest from a Content-Digest
+// (RFC 9530) or Digest (RFC 3230) header.
+func digestFromHeaders(h http.Header) (string, bool) {
+	for _, v := range strings.Split(h.Get("Content-Digest"), ",") {
+		parts := strings.SplitN(strings.TrimSpace(v), "=", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "sha-256") {
+			return strings.Trim(parts[1], ":"), true
+		}
+	}
+	for _, v := range strings.Split(h.Get("Digest"), ",") {
+		parts := strings.SplitN(strings.TrimSpace(v), "=", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "sha-256") {
+			return parts[1], true
+		}
+	}
+	return "", false
+}
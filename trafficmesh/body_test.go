@@ -0,0 +1,287 @@
+package trafficmesh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyBinding(t *testing.T) {
+	enc := NewSignatureEncoder("secret", 0, WithBodyBinding())
+	dec := NewSignatureDecoder("secret", RequireBodyBinding())
+
+	sign := func(t *testing.T, method, body string) *http.Request {
+		req := httptest.NewRequest(method, "http://example.org/form", strings.NewReader(body))
+		_, err := enc.SignRequest(req, "1", "2", "3", false)
+		require.NoError(t, err)
+		return req
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		req := sign(t, http.MethodPost, "name=value")
+		_, err := dec.DecodeSignature(req)
+		require.NoError(t, err)
+
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "name=value", string(body))
+	})
+
+	t.Run("empty body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.org/form", nil)
+		_, err := enc.SignRequest(req, "1", "2", "3", false)
+		require.NoError(t, err)
+		_, err = dec.DecodeSignature(req)
+		require.NoError(t, err)
+		_, err = ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+	})
+
+	t.Run("different method", func(t *testing.T) {
+		req := sign(t, http.MethodPost, "name=value")
+		req.Method = http.MethodPut
+		_, err := dec.DecodeSignature(req)
+		assert.True(t, errors.Is(err, ErrBindingMismatch), "unexpected error %v", err)
+	})
+
+	t.Run("different body", func(t *testing.T) {
+		req := sign(t, http.MethodPost, "name=value")
+		req.Body = ioutil.NopCloser(strings.NewReader("name=other"))
+		_, err := dec.DecodeSignature(req)
+		assert.True(t, errors.Is(err, ErrBodyDigestMismatch), "unexpected error %v", err)
+	})
+
+	t.Run("unbound token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "http://example.org/form", strings.NewReader("a"))
+		_, err := NewSignatureEncoder("secret", 0).SignRequest(req, "1", "2", "3", false)
+		require.NoError(t, err)
+
+		_, err = dec.DecodeSignature(req)
+		assert.True(t, errors.Is(err, ErrMissingClaim), "unexpected error %v", err)
+
+		// without RequireBodyBinding unbound tokens are still accepted
+		_, err = NewSignatureDecoder("secret").DecodeSignature(req)
+		require.NoError(t, err)
+	})
+
+	t.Run("bound token is verified without the option", func(t *testing.T) {
+		req := sign(t, http.MethodPost, "name=value")
+		req.Method = http.MethodDelete
+		_, err := NewSignatureDecoder("secret").DecodeSignature(req)
+		assert.True(t, errors.Is(err, ErrBindingMismatch), "unexpected error %v", err)
+	})
+}
+
+func TestBodyBindingDigestHeaders(t *testing.T) {
+	body := []byte(strings.Repeat("large body ", 100000))
+	sum := sha256.Sum256(body)
+	digest := base64.StdEncoding.EncodeToString(sum[:])
+
+	for name, header := range map[string][2]string{
+		"content-digest": {"Content-Digest", "sha-512=:abc:, sha-256=:" + digest + ":"},
+		"digest":         {"Digest", "SHA-256=" + digest},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "http://example.org/upload", bytes.NewReader(body))
+			req.Header.Set(header[0], header[1])
+
+			payload, err := NewSignatureEncoder("secret", 0, WithBodyBinding()).SignRequest(req, "1", "2", "3", false)
+			require.NoError(t, err)
+			assert.Equal(t, digest, payload.BodyDigest)
+
+			_, err = NewSignatureDecoder("secret").DecodeSignature(req)
+			require.NoError(t, err)
+			n, err := ioutil.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, body, n)
+			require.NoError(t, req.Body.Close())
+
+			req.Header.Set(header[0], strings.Replace(header[1], digest, "bm9wZQ==", 1))
+			_, err = NewSignatureDecoder("secret").DecodeSignature(req)
+			assert.True(t, errors.Is(err, ErrBindingMismatch), "unexpected error %v", err)
+		})
+	}
+}
+
+func TestBodyBindingMiddleware(t *testing.T) {
+	type transfer struct {
+		Amount int `json:"amount"`
+	}
+
+	var received []transfer
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body transfer
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received = append(received, body)
+	})
+	server := httptest.NewServer(Middleware(NewSignatureDecoder("secret", RequireBodyBinding()), ModeEnforce, logrus.New())(handler))
+	defer server.Close()
+
+	send := func(t *testing.T, signed, sent string) int {
+		req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(signed))
+		require.NoError(t, err)
+		_, err = NewSignatureEncoder("secret", 0, WithBodyBinding()).SignRequest(req, "1", "2", "3", false)
+		require.NoError(t, err)
+		req.Body = ioutil.NopCloser(strings.NewReader(sent))
+		req.GetBody = nil
+		req.ContentLength = int64(len(sent))
+
+		rsp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		rsp.Body.Close()
+		return rsp.StatusCode
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		received = nil
+		assert.Equal(t, http.StatusOK, send(t, `{"amount":1}`, `{"amount":1}`))
+		assert.Equal(t, []transfer{{Amount: 1}}, received)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		received = nil
+		assert.Equal(t, http.StatusForbidden, send(t, `{"amount":1}`, `{"amount":9}`))
+		assert.Empty(t, received)
+	})
+
+	t.Run("large body", func(t *testing.T) {
+		padding := strings.Repeat(" ", 2*maxMemoryBody)
+		received = nil
+		assert.Equal(t, http.StatusOK, send(t, `{"amount":1}`+padding, `{"amount":1}`+padding))
+		assert.Equal(t, []transfer{{Amount: 1}}, received)
+
+		received = nil
+		assert.Equal(t, http.StatusForbidden, send(t, `{"amount":1}`+padding, `{"amount":9}`+padding))
+		assert.Empty(t, received)
+	})
+}
+
+// setTempDir points os.TempDir at a fresh directory for the duration of the test
+func setTempDir(t *testing.T) string {
+	dir := t.TempDir()
+	old, ok := os.LookupEnv("TMPDIR")
+	require.NoError(t, os.Setenv("TMPDIR", dir))
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv("TMPDIR", old)
+		} else {
+			os.Unsetenv("TMPDIR")
+		}
+	})
+	return dir
+}
+
+func TestBodyBindingTempFiles(t *testing.T) {
+	dir := setTempDir(t)
+	body := strings.Repeat("a", 2*maxMemoryBody)
+	leftover := func(t *testing.T) []string {
+		files, err := filepath.Glob(filepath.Join(dir, "trafficmesh-body-*"))
+		require.NoError(t, err)
+		return files
+	}
+
+	t.Run("middleware", func(t *testing.T) {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// read part of the body and leave closing it to net/http
+			r.Body.Read(make([]byte, 16))
+			assert.Len(t, leftover(t), 1)
+		})
+		server := httptest.NewServer(Middleware(NewSignatureDecoder("secret"), ModeEnforce, logrus.New())(handler))
+		defer server.Close()
+
+		for i := 0; i < 3; i++ {
+			req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+			require.NoError(t, err)
+			_, err = NewSignatureEncoder("secret", 0, WithBodyBinding()).SignRequest(req, "1", "2", "3", false)
+			require.NoError(t, err)
+
+			rsp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			rsp.Body.Close()
+			assert.Equal(t, http.StatusOK, rsp.StatusCode)
+		}
+		assert.Empty(t, leftover(t))
+	})
+
+	t.Run("read to the end", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "http://example.org/upload", strings.NewReader(body))
+		_, err := NewSignatureEncoder("secret", 0, WithBodyBinding()).SignRequest(req, "1", "2", "3", false)
+		require.NoError(t, err)
+		_, err = NewSignatureDecoder("secret").DecodeSignature(req)
+		require.NoError(t, err)
+		assert.Len(t, leftover(t), 1)
+
+		got, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Len(t, got, len(body))
+		assert.Empty(t, leftover(t))
+	})
+
+	t.Run("digest mismatch", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "http://example.org/upload", strings.NewReader(body))
+		_, err := NewSignatureEncoder("secret", 0, WithBodyBinding()).SignRequest(req, "1", "2", "3", false)
+		require.NoError(t, err)
+		req.Body = ioutil.NopCloser(strings.NewReader(strings.Repeat("b", len(body))))
+		_, err = NewSignatureDecoder("secret").DecodeSignature(req)
+		assert.True(t, errors.Is(err, ErrBodyDigestMismatch), "unexpected error %v", err)
+
+		require.NoError(t, req.Body.Close())
+		assert.Empty(t, leftover(t))
+	})
+}
+
+func TestBodyBindingMaxBodySize(t *testing.T) {
+	dir := setTempDir(t)
+	body := strings.Repeat("a", 2*maxMemoryBody)
+	dec := NewSignatureDecoder("secret", WithMaxBodySize(maxMemoryBody+10))
+
+	sign := func(t *testing.T) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "http://example.org/upload", strings.NewReader(body))
+		_, err := NewSignatureEncoder("secret", 0, WithBodyBinding()).SignRequest(req, "1", "2", "3", false)
+		require.NoError(t, err)
+		return req
+	}
+
+	t.Run("content length", func(t *testing.T) {
+		_, err := dec.DecodeSignature(sign(t))
+		var tooLarge *BodyTooLargeError
+		require.True(t, errors.As(err, &tooLarge), "unexpected error %v", err)
+		assert.EqualValues(t, maxMemoryBody+10, tooLarge.Limit)
+	})
+
+	t.Run("while reading", func(t *testing.T) {
+		req := sign(t)
+		req.ContentLength = -1
+		_, err := dec.DecodeSignature(req)
+		var tooLarge *BodyTooLargeError
+		require.True(t, errors.As(err, &tooLarge), "unexpected error %v", err)
+
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+
+	t.Run("middleware", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		Middleware(dec, ModeReportOnly, logrus.New())(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			t.Error("handler called for a body that is too large")
+		})).ServeHTTP(rec, sign(t))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}
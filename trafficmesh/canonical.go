@@ -0,0 +1,204 @@
+package trafficmesh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// URLMatch controls how strictly the URL claim has to match the request.
+type URLMatch int
+
+const (
+	// URLMatchCanonical compares the canonical form of both URLs: the host is lowercased and
+	// stripped of the scheme's default port, percent-encoding is normalized and dot segments
+	// are removed from the path.
+	URLMatchCanonical URLMatch = iota
+	// URLMatchCanonicalAnyQueryOrder is URLMatchCanonical, but additionally ignores the order
+	// of query parameters.
+	URLMatchCanonicalAnyQueryOrder
+	// URLMatchExact compares the host and request URI byte for byte.
+	URLMatchExact
+)
+
+// WithURLMatch sets how the URL claim is matched against the request. The default is
+// URLMatchCanonical.
+func WithURLMatch(m URLMatch) DecoderOption {
+	return func(d *SignatureDecoder) {
+		d.urlMatch = m
+	}
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+func (d *SignatureDecoder) matchURL(payloadURL string, req *http.Request) error {
+	u, err := url.Parse(payloadURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse token url: %w", err)
+	}
+	if u.Host == "" {
+		return errors.New("token url has no host")
+	}
+
+	if d.urlMatch == URLMatchExact {
+		if u.Host != req.Host {
+			return fmt.Errorf("token host %s doesn't match request host: %s", u.Host, req.Host)
+		}
+		if payloadURI, reqURI := u.RequestURI(), req.URL.RequestURI(); payloadURI != reqURI {
+			return fmt.Errorf("token uri %s doesn't match request uri: %s", payloadURI, reqURI)
+		}
+		return nil
+	}
+
+	reqScheme := req.URL.Scheme
+	if reqScheme == "" {
+		if req.TLS != nil {
+			reqScheme = "https"
+		} else {
+			// the request was likely forwarded by the mesh, assume it kept the signed scheme
+			reqScheme = u.Scheme
+		}
+	}
+
+	if payloadHost, reqHost := canonicalHost(u.Host, u.Scheme), canonicalHost(req.Host, reqScheme); payloadHost != reqHost {
+		return fmt.Errorf("token host %s doesn't match request host: %s", u.Host, req.Host)
+	}
+
+	sortQuery := d.urlMatch == URLMatchCanonicalAnyQueryOrder
+	payloadURI := canonicalURI(u.EscapedPath(), u.RawQuery, sortQuery)
+	reqURI := canonicalURI(req.URL.EscapedPath(), req.URL.RawQuery, sortQuery)
+	if payloadURI != reqURI {
+		return fmt.Errorf("token uri %s doesn't match request uri: %s", u.RequestURI(), req.URL.RequestURI())
+	}
+	return nil
+}
+
+// canonicalHost lowercases host and strips the default port of scheme.
+func canonicalHost(host, scheme string) string {
+	host = strings.ToLower(host)
+	if h, port, err := net.SplitHostPort(host); err == nil && port == defaultPorts[strings.ToLower(scheme)] {
+		host = h
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+	}
+	return host
+}
+
+// canonicalURI normalizes the escaped path and raw query of a URL as described in RFC 3986
+// section 6.2.2.
+func canonicalURI(path, query string, sortQuery bool) string {
+	path = removeDotSegments(normalizeEscapes(path))
+	if path == "" {
+		path = "/"
+	}
+	if query == "" {
+		return path
+	}
+
+	params := strings.Split(query, "&")
+	for i, p := range params {
+		params[i] = normalizeEscapes(p)
+	}
+	if sortQuery {
+		sort.Strings(params)
+	}
+	return path + "?" + strings.Join(params, "&")
+}
+
+// normalizeEscapes decodes percent-encoded unreserved characters and uppercases the hex
+// digits of all other escapes.
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+// removeDotSegments implements the algorithm from RFC 3986 section 5.2.4.
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+
+	var out []string
+	in := path
+	for in != "" {
+		switch {
+		case strings.HasPrefix(in, "../"):
+			in = in[3:]
+		case strings.HasPrefix(in, "./"):
+			in = in[2:]
+		case strings.HasPrefix(in, "/./"):
+			in = in[2:]
+		case in == "/.":
+			in = "/"
+		case strings.HasPrefix(in, "/../"):
+			in = in[3:]
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case in == "/..":
+			in = "/"
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case in == "." || in == "..":
+			in = ""
+		default:
+			next := strings.Index(in[1:], "/")
+			if next == -1 {
+				out = append(out, in)
+				in = ""
+			} else {
+				out = append(out, in[:next+1])
+				in = in[next+1:]
+			}
+		}
+	}
+	return strings.Join(out, "")
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
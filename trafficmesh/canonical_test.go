@@ -0,0 +1,75 @@
+package trafficmesh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		payloadURL string
+		reqHost    string
+		reqURI     string
+		match      URLMatch
+		ok         bool
+	}{
+		{"identical", "https://example.org/index.html", "example.org", "/index.html", URLMatchCanonical, true},
+		{"host case", "https://Example.ORG/index.html", "example.org", "/index.html", URLMatchCanonical, true},
+		{"default https port in token", "https://example.org:443/", "example.org", "/", URLMatchCanonical, true},
+		{"default https port in request", "https://example.org/", "example.org:443", "/", URLMatchCanonical, true},
+		{"default http port", "http://example.org:80/", "example.org", "/", URLMatchCanonical, true},
+		{"non-default port", "https://example.org:8443/", "example.org", "/", URLMatchCanonical, false},
+		{"other scheme's default port", "https://example.org:80/", "example.org", "/", URLMatchCanonical, false},
+		{"ipv6 default port", "https://[::1]:443/", "[::1]", "/", URLMatchCanonical, true},
+		{"different host", "https://example.net/", "example.org", "/", URLMatchCanonical, false},
+		{"no host", "/index.html", "example.org", "/index.html", URLMatchCanonical, false},
+		{"no host exact", "/index.html", "", "/index.html", URLMatchExact, false},
+		{"empty path", "https://example.org", "example.org", "/", URLMatchCanonical, true},
+		{"encoded unreserved", "https://example.org/%7Euser/%61bc", "example.org", "/~user/abc", URLMatchCanonical, true},
+		{"escape case", "https://example.org/a%2fb", "example.org", "/a%2Fb", URLMatchCanonical, true},
+		{"encoded reserved stays encoded", "https://example.org/a%2Fb", "example.org", "/a/b", URLMatchCanonical, false},
+		{"dot segments", "https://example.org/a/./b/../c", "example.org", "/a/c", URLMatchCanonical, true},
+		{"encoded dot segments", "https://example.org/a/%2E%2E/c", "example.org", "/c", URLMatchCanonical, true},
+		{"different path", "https://example.org/a", "example.org", "/b", URLMatchCanonical, false},
+		{"query escapes", "https://example.org/?q=%7e", "example.org", "/?q=~", URLMatchCanonical, true},
+		{"query order matters", "https://example.org/?a=1&b=2", "example.org", "/?b=2&a=1", URLMatchCanonical, false},
+		{"query order ignored", "https://example.org/?a=1&b=2", "example.org", "/?b=2&a=1", URLMatchCanonicalAnyQueryOrder, true},
+		{"query values still compared", "https://example.org/?a=1&b=2", "example.org", "/?b=2&a=3", URLMatchCanonicalAnyQueryOrder, false},
+		{"exact rejects host case", "https://Example.org/", "example.org", "/", URLMatchExact, false},
+		{"exact rejects default port", "https://example.org:443/", "example.org", "/", URLMatchExact, false},
+		{"exact identical", "https://example.org/a?b=c", "example.org", "/a?b=c", URLMatchExact, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dec := NewSignatureDecoder("secret", WithURLMatch(tc.match))
+			req := httptest.NewRequest(http.MethodGet, tc.reqURI, nil)
+			req.Host = tc.reqHost
+
+			err := dec.matchURL(tc.payloadURL, req)
+			if tc.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestRemoveDotSegments(t *testing.T) {
+	tests := map[string]string{
+		"/a/b/c/./../../g":   "/a/g",
+		"mid/content=5/../6": "mid/6",
+		"/../a":              "/a",
+		"/a/..":              "/",
+		"/a/.":               "/a/",
+		"/a//b":              "/a//b",
+		"":                   "",
+	}
+	for in, expected := range tests {
+		assert.Equal(t, expected, removeDotSegments(in), in)
+	}
+}
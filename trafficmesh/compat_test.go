@@ -0,0 +1,114 @@
+package trafficmesh
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// historical_tokens.json holds tokens issued by the jwt-go based implementation, so that
+// changes to the decoder can't silently reject valid mesh traffic.
+type historicalTokens struct {
+	Keys   *KeyringConfig `json:"keys"`
+	Tokens []struct {
+		Name      string `json:"name"`
+		Token     string `json:"token"`
+		Host      string `json:"host"`
+		URI       string `json:"uri"`
+		Valid     bool   `json:"valid"`
+		Now       int64  `json:"now"`
+		SiteID    string `json:"sid"`
+		DeployID  string `json:"did"`
+		AccountID string `json:"aid"`
+		Remapped  bool   `json:"remapped"`
+	} `json:"tokens"`
+}
+
+func TestHistoricalTokens(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/historical_tokens.json")
+	require.NoError(t, err)
+
+	fixture := new(historicalTokens)
+	require.NoError(t, json.Unmarshal(data, fixture))
+
+	keys, err := NewKeyring(fixture.Keys)
+	require.NoError(t, err)
+
+	for _, tc := range fixture.Tokens {
+		t.Run(tc.Name, func(t *testing.T) {
+			dec := NewSignatureDecoderWithKeyring(keys, WithAlgorithms(AlgorithmHS256, AlgorithmES256, AlgorithmEdDSA))
+			now := time.Unix(tc.Now, 0)
+			dec.now = func() time.Time { return now }
+
+			req := httptest.NewRequest(http.MethodGet, "https://192.0.2.1"+tc.URI, nil)
+			req.Host = tc.Host
+			req.Header.Set(signatureHeader, tc.Token)
+
+			payload, err := dec.DecodeSignature(req)
+			if !tc.Valid {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.SiteID, payload.SiteID)
+			assert.Equal(t, tc.DeployID, payload.DeployID)
+			assert.Equal(t, tc.AccountID, payload.AccountID)
+			assert.Equal(t, tc.Remapped, payload.Remapped)
+		})
+	}
+}
+
+func TestRejectNoneAlgorithm(t *testing.T) {
+	token := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"url": "http://example.org/"})
+	sig, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.org/", nil)
+	req.Header.Set(signatureHeader, sig)
+
+	_, err = NewSignatureDecoder("secret", WithAlgorithms(AlgorithmHS256, "none")).DecodeSignature(req)
+	require.Error(t, err)
+}
+
+func TestAudienceAndIssuer(t *testing.T) {
+	dec := NewSignatureDecoder("secret", RequireAudience("origin"), RequireIssuer("edge"))
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		err    error
+	}{
+		{"valid", jwt.MapClaims{"aud": "origin", "iss": "edge"}, nil},
+		{"audience list", jwt.MapClaims{"aud": []string{"other", "origin"}, "iss": "edge"}, nil},
+		{"wrong audience", jwt.MapClaims{"aud": "other", "iss": "edge"}, ErrInvalidAudience},
+		{"missing audience", jwt.MapClaims{"iss": "edge"}, ErrInvalidAudience},
+		{"wrong issuer", jwt.MapClaims{"aud": "origin", "iss": "someone"}, ErrInvalidIssuer},
+		{"missing issuer", jwt.MapClaims{"aud": "origin"}, ErrInvalidIssuer},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := dec.DecodeSignature(signedRequest(t, "secret", tc.claims))
+			if tc.err == nil {
+				require.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, tc.err), "expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+
+	t.Run("encoder", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.org/", nil)
+		_, err := NewSignatureEncoder("secret", 0, WithAudience("origin"), WithIssuer("edge")).SignRequest(req, "1", "2", "3", false)
+		require.NoError(t, err)
+		_, err = dec.DecodeSignature(req)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,157 @@
+package trafficmesh
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// DefaultSignatureTTL is how long an issued signature stays valid when no TTL is configured.
+const DefaultSignatureTTL = time.Minute
+
+// SignatureEncoder issues signed traffic-mesh headers that SignatureDecoder can verify.
+type SignatureEncoder struct {
+	keys     *Keyring
+	ttl      time.Duration
+	issuer   string
+	audience string
+	bindBody bool
+	now      func() time.Time
+}
+
+// EncoderOption configures a SignatureEncoder.
+type EncoderOption func(*SignatureEncoder)
+
+// WithIssuer sets the iss claim of every issued token.
+func WithIssuer(iss string) EncoderOption {
+	return func(e *SignatureEncoder) {
+		e.issuer = iss
+	}
+}
+
+// WithAudience sets the aud claim of every issued token.
+func WithAudience(aud string) EncoderOption {
+	return func(e *SignatureEncoder) {
+		e.audience = aud
+	}
+}
+
+// NewSignatureEncoder constructs a new SignatureEncoder. Issued signatures expire after ttl,
+// or DefaultSignatureTTL when ttl is not positive.
+func NewSignatureEncoder(secret string, ttl time.Duration, opts ...EncoderOption) *SignatureEncoder {
+	return NewSignatureEncoderWithKeyring(singleKeyring(secret), ttl, opts...)
+}
+
+// NewSignatureEncoderWithKeyring constructs a new SignatureEncoder that signs with the keyring's
+// signing key and names it in the kid header.
+func NewSignatureEncoderWithKeyring(keys *Keyring, ttl time.Duration, opts ...EncoderOption) *SignatureEncoder {
+	if ttl <= 0 {
+		ttl = DefaultSignatureTTL
+	}
+	e := &SignatureEncoder{
+		keys: keys,
+		ttl:  ttl,
+		now:  time.Now,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// NewSignaturePayload builds the payload for a request. The URL claim is derived from the
+// request host and URI so it matches what DecodeSignature checks on the receiving end.
+func NewSignaturePayload(req *http.Request, siteID, deployID, accountID string, remapped bool) *SignaturePayload {
+	return &SignaturePayload{
+		SiteID:    siteID,
+		DeployID:  deployID,
+		AccountID: accountID,
+		URL:       requestURL(req),
+		Remapped:  remapped,
+	}
+}
+
+// EncodeSignature sets the iat, nbf and exp claims of the payload and signs it. A random jti
+// is set when the payload has none, so the token can be used with a replay cache.
+func (e *SignatureEncoder) EncodeSignature(payload *SignaturePayload) (string, error) {
+	key, err := e.keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	if payload.ID == "" {
+		id, err := newTokenID()
+		if err != nil {
+			return "", err
+		}
+		payload.ID = id
+	}
+	if e.issuer != "" {
+		payload.Issuer = e.issuer
+	}
+	if e.audience != "" {
+		payload.Audience = jwt.ClaimStrings{e.audience}
+	}
+
+	now := e.now()
+	payload.IssuedAt = jwt.NewNumericDate(now)
+	payload.NotBefore = jwt.NewNumericDate(now)
+	payload.ExpiresAt = jwt.NewNumericDate(now.Add(e.ttl))
+
+	method := jwt.GetSigningMethod(key.Algorithm)
+	if method == nil {
+		return "", fmt.Errorf("unsupported signing algorithm: %s", key.Algorithm)
+	}
+	token := jwt.NewWithClaims(method, payload)
+	if key.ID != "" {
+		token.Header[keyIDHeader] = key.ID
+	}
+	sig, err := token.SignedString(key.signKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign traffic mesh payload: %w", err)
+	}
+	return sig, nil
+}
+
+// SignRequest builds a payload for the request, signs it and sets the signature header.
+func (e *SignatureEncoder) SignRequest(req *http.Request, siteID, deployID, accountID string, remapped bool) (*SignaturePayload, error) {
+	payload := NewSignaturePayload(req, siteID, deployID, accountID, remapped)
+	if e.bindBody {
+		if err := e.bind(req, payload); err != nil {
+			return nil, err
+		}
+	}
+	sig, err := e.EncodeSignature(payload)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(signatureHeader, sig)
+	return payload, nil
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func requestURL(req *http.Request) string {
+	scheme := req.URL.Scheme
+	if scheme == "" {
+		scheme = "http"
+		if req.TLS != nil {
+			scheme = "https"
+		}
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	return scheme + "://" + host + req.URL.RequestURI()
+}
@@ -0,0 +1,52 @@
+package trafficmesh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureEncoderRoundTrip(t *testing.T) {
+	enc := NewSignatureEncoder("secret", 0)
+	dec := NewSignatureDecoder("secret")
+
+	req := httptest.NewRequest(http.MethodGet, "https://192.0.2.1/index.html?a=b", nil)
+	req.Host = "example.org"
+
+	issued, err := enc.SignRequest(req, "1", "2", "3", true)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org/index.html?a=b", issued.URL)
+	assert.NotEmpty(t, req.Header.Get(signatureHeader))
+
+	payload, err := dec.DecodeSignature(req)
+	require.NoError(t, err)
+	assert.Equal(t, "1", payload.SiteID)
+	assert.Equal(t, "2", payload.DeployID)
+	assert.Equal(t, "3", payload.AccountID)
+	assert.True(t, payload.Remapped)
+	assert.Equal(t, issued.ExpiresAt, payload.ExpiresAt)
+	assert.Equal(t, payload.IssuedAt.Add(DefaultSignatureTTL), payload.ExpiresAt.Time)
+}
+
+func TestSignatureEncoderExpired(t *testing.T) {
+	enc := NewSignatureEncoder("secret", time.Minute)
+	enc.now = func() time.Time { return time.Now().Add(-time.Hour) }
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.org/", nil)
+	_, err := enc.SignRequest(req, "1", "2", "3", false)
+	require.NoError(t, err)
+
+	_, err = NewSignatureDecoder("secret").DecodeSignature(req)
+	require.Error(t, err)
+}
+
+func TestSignatureEncoderNoSecret(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.org/", nil)
+	_, err := NewSignatureEncoder("", 0).SignRequest(req, "1", "2", "3", false)
+	require.Error(t, err)
+	assert.Empty(t, req.Header.Get(signatureHeader))
+}
@@ -0,0 +1,255 @@
+package trafficmesh
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// KeyringConfig describes the keys of a Keyring. Every map is keyed by key ID, and an ID may
+// only appear once across them. Keys holds HS256 secrets, PublicKeys holds PEM encoded ES256
+// (P-256) or EdDSA (Ed25519) public keys for verification only, and PrivateKeys holds PEM
+// encoded private keys that can both sign and verify. SigningKey names the key used to sign
+// new tokens. The key ID "" is used for tokens that carry no kid header.
+type KeyringConfig struct {
+	SigningKey  string            `json:"signing_key"`
+	Keys        map[string]string `json:"keys"`
+	PublicKeys  map[string]string `json:"public_keys"`
+	PrivateKeys map[string]string `json:"private_keys"`
+}
+
+// KeySource loads a keyring configuration, e.g. from a file or a secrets store.
+type KeySource func() (*KeyringConfig, error)
+
+// Key is a single key in a Keyring. The algorithm is bound to the key material, so a key can
+// only ever verify tokens of its own algorithm.
+type Key struct {
+	ID        string
+	Algorithm string
+
+	verifyKey interface{}
+	signKey   interface{}
+}
+
+// CanSign reports whether the key holds the material needed to sign tokens.
+func (k Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// Keyring holds the keys used to sign and verify traffic-mesh signatures. It is safe
+// for concurrent use and can be reloaded at runtime, which allows rotating the secret
+// without a synchronized deploy of every producer and consumer.
+type Keyring struct {
+	mtx        sync.RWMutex
+	keys       map[string]Key
+	signingKID string
+}
+
+// NewKeyring constructs a keyring from the given configuration.
+func NewKeyring(config *KeyringConfig) (*Keyring, error) {
+	k := new(Keyring)
+	if err := k.Set(config); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// NewKeyringFromSource constructs a keyring and loads its keys from source.
+func NewKeyringFromSource(source KeySource) (*Keyring, error) {
+	k := new(Keyring)
+	if err := k.Reload(source); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// singleKeyring is the keyring behind the plain secret constructors. An empty secret
+// results in an empty keyring.
+func singleKeyring(secret string) *Keyring {
+	k := &Keyring{keys: make(map[string]Key)}
+	if secret != "" {
+		k.keys[""] = hmacKey("", secret)
+	}
+	return k
+}
+
+// Set atomically replaces all keys in the keyring.
+func (k *Keyring) Set(config *KeyringConfig) error {
+	if config == nil {
+		return errors.New("no keyring config provided")
+	}
+
+	keys := make(map[string]Key, len(config.Keys)+len(config.PublicKeys)+len(config.PrivateKeys))
+	add := func(key Key) error {
+		if _, ok := keys[key.ID]; ok {
+			return fmt.Errorf("key %q is defined more than once", key.ID)
+		}
+		keys[key.ID] = key
+		return nil
+	}
+
+	for kid, secret := range config.Keys {
+		if secret == "" {
+			return fmt.Errorf("key %q has an empty secret", kid)
+		}
+		if err := add(hmacKey(kid, secret)); err != nil {
+			return err
+		}
+	}
+	for kid, data := range config.PublicKeys {
+		key, err := parsePublicKey(kid, data)
+		if err != nil {
+			return err
+		}
+		if err := add(key); err != nil {
+			return err
+		}
+	}
+	for kid, data := range config.PrivateKeys {
+		key, err := parsePrivateKey(kid, data)
+		if err != nil {
+			return err
+		}
+		if err := add(key); err != nil {
+			return err
+		}
+	}
+
+	if config.SigningKey != "" {
+		key, ok := keys[config.SigningKey]
+		if !ok {
+			return fmt.Errorf("signing key %q is not in the keyring", config.SigningKey)
+		}
+		if !key.CanSign() {
+			return fmt.Errorf("signing key %q is a public key", config.SigningKey)
+		}
+	}
+
+	k.mtx.Lock()
+	k.keys = keys
+	k.signingKID = config.SigningKey
+	k.mtx.Unlock()
+	return nil
+}
+
+// Reload replaces the keys with the ones loaded from source. The current keys are
+// kept when loading fails.
+func (k *Keyring) Reload(source KeySource) error {
+	config, err := source()
+	if err != nil {
+		return fmt.Errorf("failed to load traffic mesh keys: %w", err)
+	}
+	return k.Set(config)
+}
+
+// Empty reports whether the keyring holds no keys.
+func (k *Keyring) Empty() bool {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+	return len(k.keys) == 0
+}
+
+// VerificationKey returns the key for the given key ID.
+func (k *Keyring) VerificationKey(kid string) (Key, bool) {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+	key, ok := k.keys[kid]
+	return key, ok
+}
+
+// SigningKey returns the key used to sign new tokens.
+func (k *Keyring) SigningKey() (Key, error) {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+	key, ok := k.keys[k.signingKID]
+	if !ok || !key.CanSign() {
+		return Key{}, errors.New("no traffic mesh signing key configured")
+	}
+	return key, nil
+}
+
+// FileKeySource returns a KeySource that reads a JSON encoded KeyringConfig from path.
+func FileKeySource(path string) KeySource {
+	return func() (*KeyringConfig, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening keyring file: %w", err)
+		}
+		defer f.Close()
+
+		config := new(KeyringConfig)
+		if err := json.NewDecoder(f).Decode(config); err != nil {
+			return nil, fmt.Errorf("error decoding keyring file: %w", err)
+		}
+		return config, nil
+	}
+}
+
+func hmacKey(kid, secret string) Key {
+	return Key{
+		ID:        kid,
+		Algorithm: AlgorithmHS256,
+		verifyKey: []byte(secret),
+		signKey:   []byte(secret),
+	}
+}
+
+func parsePublicKey(kid, data string) (Key, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return Key{}, fmt.Errorf("key %q is not PEM encoded", kid)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to parse public key %q: %w", kid, err)
+	}
+
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return Key{}, fmt.Errorf("key %q: only P-256 ECDSA keys are supported", kid)
+		}
+		return Key{ID: kid, Algorithm: AlgorithmES256, verifyKey: pub}, nil
+	case ed25519.PublicKey:
+		return Key{ID: kid, Algorithm: AlgorithmEdDSA, verifyKey: pub}, nil
+	default:
+		return Key{}, fmt.Errorf("key %q: unsupported public key type %T", kid, pub)
+	}
+}
+
+func parsePrivateKey(kid, data string) (Key, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return Key{}, fmt.Errorf("key %q is not PEM encoded", kid)
+	}
+
+	var priv interface{}
+	var err error
+	if block.Type == "EC PRIVATE KEY" {
+		priv, err = x509.ParseECPrivateKey(block.Bytes)
+	} else {
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to parse private key %q: %w", kid, err)
+	}
+
+	switch priv := priv.(type) {
+	case *ecdsa.PrivateKey:
+		if priv.Curve != elliptic.P256() {
+			return Key{}, fmt.Errorf("key %q: only P-256 ECDSA keys are supported", kid)
+		}
+		return Key{ID: kid, Algorithm: AlgorithmES256, verifyKey: &priv.PublicKey, signKey: priv}, nil
+	case ed25519.PrivateKey:
+		return Key{ID: kid, Algorithm: AlgorithmEdDSA, verifyKey: priv.Public(), signKey: priv}, nil
+	default:
+		return Key{}, fmt.Errorf("key %q: unsupported private key type %T", kid, priv)
+	}
+}
@@ -0,0 +1,119 @@
+package trafficmesh
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyringRotation(t *testing.T) {
+	producer, err := NewKeyring(&KeyringConfig{
+		SigningKey: "old",
+		Keys:       map[string]string{"old": "s1"},
+	})
+	require.NoError(t, err)
+	consumer, err := NewKeyring(&KeyringConfig{
+		Keys: map[string]string{"old": "s1", "new": "s2"},
+	})
+	require.NoError(t, err)
+
+	enc := NewSignatureEncoderWithKeyring(producer, 0)
+	dec := NewSignatureDecoderWithKeyring(consumer)
+
+	sign := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://example.org/", nil)
+		_, err := enc.SignRequest(req, "1", "2", "3", false)
+		require.NoError(t, err)
+		return req
+	}
+
+	_, err = dec.DecodeSignature(sign())
+	require.NoError(t, err)
+
+	// the producer switches to the new key, consumers accept both
+	require.NoError(t, producer.Set(&KeyringConfig{
+		SigningKey: "new",
+		Keys:       map[string]string{"new": "s2"},
+	}))
+	_, err = dec.DecodeSignature(sign())
+	require.NoError(t, err)
+
+	// once the old key is retired, tokens signed with it are rejected
+	old := NewSignatureEncoderWithKeyring(mustKeyring(t, "old", "s1"), 0)
+	require.NoError(t, consumer.Set(&KeyringConfig{Keys: map[string]string{"new": "s2"}}))
+	req := httptest.NewRequest(http.MethodGet, "http://example.org/", nil)
+	_, err = old.SignRequest(req, "1", "2", "3", false)
+	require.NoError(t, err)
+	_, err = dec.DecodeSignature(req)
+	require.Error(t, err)
+}
+
+func TestKeyringLegacyTokens(t *testing.T) {
+	keys := mustKeyring(t, "", "secret")
+	keys.keys["new"] = hmacKey("new", "other")
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.org/", nil)
+	_, err := NewSignatureEncoder("secret", 0).SignRequest(req, "1", "2", "3", false)
+	require.NoError(t, err)
+
+	_, err = NewSignatureDecoderWithKeyring(keys).DecodeSignature(req)
+	require.NoError(t, err)
+}
+
+func TestKeyringInvalidConfig(t *testing.T) {
+	_, err := NewKeyring(&KeyringConfig{SigningKey: "missing", Keys: map[string]string{"a": "b"}})
+	require.Error(t, err)
+
+	_, err = NewKeyring(&KeyringConfig{Keys: map[string]string{"a": ""}})
+	require.Error(t, err)
+
+	_, err = NewKeyring(nil)
+	require.Error(t, err)
+}
+
+func TestKeyringReload(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	require.NoError(t, json.NewEncoder(f).Encode(&KeyringConfig{
+		SigningKey: "a",
+		Keys:       map[string]string{"a": "secret-a"},
+	}))
+
+	keys, err := NewKeyringFromSource(FileKeySource(f.Name()))
+	require.NoError(t, err)
+	key, err := keys.SigningKey()
+	require.NoError(t, err)
+	assert.Equal(t, "a", key.ID)
+	assert.Equal(t, AlgorithmHS256, key.Algorithm)
+
+	// a failing source keeps the current keys
+	require.Error(t, keys.Reload(func() (*KeyringConfig, error) {
+		return nil, errors.New("boom")
+	}))
+	_, ok := keys.VerificationKey("a")
+	assert.True(t, ok)
+
+	require.NoError(t, keys.Reload(func() (*KeyringConfig, error) {
+		return &KeyringConfig{SigningKey: "b", Keys: map[string]string{"b": "secret-b"}}, nil
+	}))
+	_, ok = keys.VerificationKey("a")
+	assert.False(t, ok)
+}
+
+func mustKeyring(t *testing.T, kid, secret string) *Keyring {
+	keys, err := NewKeyring(&KeyringConfig{
+		SigningKey: kid,
+		Keys:       map[string]string{kid: secret},
+	})
+	require.NoError(t, err)
+	return keys
+}
@@ -0,0 +1,100 @@
+package trafficmesh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Mode controls how Middleware handles requests without a valid signature.
+type Mode int
+
+const (
+	// ModeEnforce rejects requests without a signature with 401 and requests with an invalid
+	// signature with 403.
+	ModeEnforce Mode = iota
+	// ModeReportOnly logs missing and invalid signatures and lets every request through.
+	ModeReportOnly
+	// ModeOptional lets requests without a signature through, but rejects requests with an
+	// invalid signature with 403.
+	ModeOptional
+)
+
+type contextKey int
+
+const (
+	payloadKey contextKey = iota
+	loggerKey
+)
+
+// Middleware decodes the traffic-mesh signature of every request and stores the payload and a
+// request logger with the payload's fields in the request context. Use PayloadFromContext and
+// LoggerFromContext to retrieve them. A decoder with an empty keyring never yields a payload,
+// so ModeEnforce rejects every request in that case. Bound bodies larger than the decoder's
+// maximum body size are rejected with 413 in every mode, as the body has been partly consumed.
+func Middleware(dec *SignatureDecoder, mode Mode, log logrus.FieldLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var reqLog logrus.FieldLogger = log.WithField("component", "trafficmesh")
+
+			payload, err := dec.DecodeSignature(r)
+			// net/http only closes the original body, remove a spooled copy ourselves
+			if body, ok := r.Body.(*spooledBody); ok {
+				defer body.Close()
+			}
+			switch {
+			case err != nil:
+				reqLog.WithError(err).Warn("Invalid traffic mesh signature")
+				var tooLarge *BodyTooLargeError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+					return
+				}
+				if mode != ModeReportOnly {
+					http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					return
+				}
+			case payload == nil:
+				if mode == ModeEnforce {
+					reqLog.Warn("Missing traffic mesh signature")
+					http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+					return
+				}
+				if mode == ModeReportOnly {
+					reqLog.Info("Missing traffic mesh signature")
+				}
+			}
+
+			ctx := r.Context()
+			if payload != nil {
+				reqLog = payload.AddFields(reqLog)
+				ctx = WithPayload(ctx, payload)
+			}
+			ctx = context.WithValue(ctx, loggerKey, reqLog)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// WithPayload returns a copy of ctx carrying the payload.
+func WithPayload(ctx context.Context, payload *SignaturePayload) context.Context {
+	return context.WithValue(ctx, payloadKey, payload)
+}
+
+// PayloadFromContext returns the payload stored by Middleware, or nil when the request had no
+// valid signature.
+func PayloadFromContext(ctx context.Context) *SignaturePayload {
+	payload, _ := ctx.Value(payloadKey).(*SignaturePayload)
+	return payload
+}
+
+// LoggerFromContext returns the request logger stored by Middleware, falling back to log when
+// the context has none.
+func LoggerFromContext(ctx context.Context, log logrus.FieldLogger) logrus.FieldLogger {
+	if l, ok := ctx.Value(loggerKey).(logrus.FieldLogger); ok {
+		return l
+	}
+	return log
+}
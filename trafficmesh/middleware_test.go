@@ -0,0 +1,74 @@
+package trafficmesh
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	dec := NewSignatureDecoder("secret")
+
+	makeReq := func(t *testing.T, secret string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://example.org/", nil)
+		if secret != "" {
+			_, err := NewSignatureEncoder(secret, 0).SignRequest(req, "1", "2", "3", false)
+			require.NoError(t, err)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name    string
+		mode    Mode
+		secret  string
+		status  int
+		payload bool
+	}{
+		{"enforce valid", ModeEnforce, "secret", http.StatusOK, true},
+		{"enforce missing", ModeEnforce, "", http.StatusUnauthorized, false},
+		{"enforce invalid", ModeEnforce, "wrong", http.StatusForbidden, false},
+		{"report-only valid", ModeReportOnly, "secret", http.StatusOK, true},
+		{"report-only missing", ModeReportOnly, "", http.StatusOK, false},
+		{"report-only invalid", ModeReportOnly, "wrong", http.StatusOK, false},
+		{"optional valid", ModeOptional, "secret", http.StatusOK, true},
+		{"optional missing", ModeOptional, "", http.StatusOK, false},
+		{"optional invalid", ModeOptional, "wrong", http.StatusForbidden, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			log, _ := test.NewNullLogger()
+
+			var called bool
+			var payload *SignaturePayload
+			var reqLog logrus.FieldLogger
+			h := Middleware(dec, tc.mode, log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				payload = PayloadFromContext(r.Context())
+				reqLog = LoggerFromContext(r.Context(), nil)
+			}))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, makeReq(t, tc.secret))
+
+			assert.Equal(t, tc.status, rec.Code)
+			assert.Equal(t, tc.status == http.StatusOK, called)
+			if !called {
+				return
+			}
+			require.NotNil(t, reqLog)
+			if tc.payload {
+				require.NotNil(t, payload)
+				assert.Equal(t, "1", payload.SiteID)
+				assert.Equal(t, "3", reqLog.(*logrus.Entry).Data["account_id"])
+			} else {
+				assert.Nil(t, payload)
+			}
+		})
+	}
+}
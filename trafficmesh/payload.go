@@ -0,0 +1,113 @@
+package trafficmesh
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DeployContext is the context of the deploy a request is served from.
+type DeployContext string
+
+// Deploy contexts set by the mesh.
+const (
+	ContextProduction    DeployContext = "production"
+	ContextDeployPreview DeployContext = "deploy-preview"
+	ContextBranchDeploy  DeployContext = "branch-deploy"
+)
+
+// knownClaims are the JSON names of all claims mapped to SignaturePayload fields.
+var knownClaims = jsonNames(reflect.TypeOf(SignaturePayload{}))
+
+// payloadJSON has the fields of SignaturePayload but not its JSON methods.
+type payloadJSON SignaturePayload
+
+// UnmarshalJSON decodes the payload and collects unknown claims into Extra.
+func (p *SignaturePayload) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*payloadJSON)(p)); err != nil {
+		return err
+	}
+
+	var all map[string]interface{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	for name := range knownClaims {
+		delete(all, name)
+	}
+	p.Extra = nil
+	if len(all) > 0 {
+		p.Extra = all
+	}
+	return nil
+}
+
+// MarshalJSON encodes the payload including the claims in Extra. Extra can't override
+// known claims.
+func (p SignaturePayload) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(payloadJSON(p))
+	if err != nil || len(p.Extra) == 0 {
+		return data, err
+	}
+
+	all := make(map[string]interface{}, len(p.Extra))
+	for name, value := range p.Extra {
+		if !knownClaims[name] {
+			all[name] = value
+		}
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	return json.Marshal(all)
+}
+
+// Fields returns the payload's IDs and any optional metadata that is set as log fields.
+func (p *SignaturePayload) Fields() logrus.Fields {
+	fields := logrus.Fields{
+		"site_id":    p.SiteID,
+		"deploy_id":  p.DeployID,
+		"account_id": p.AccountID,
+	}
+	for name, value := range map[string]string{
+		"deploy_context": string(p.Context),
+		"branch":         p.Branch,
+		"edge_pop":       p.POP,
+		"edge_region":    p.Region,
+		"client_ip":      p.ClientIP,
+		"request_id":     p.RequestID,
+	} {
+		if value != "" {
+			fields[name] = value
+		}
+	}
+	return fields
+}
+
+// AddFields returns log with the payload's fields attached. It is safe to call on a nil payload.
+func (p *SignaturePayload) AddFields(log logrus.FieldLogger) logrus.FieldLogger {
+	if p == nil {
+		return log
+	}
+	return log.WithFields(p.Fields())
+}
+
+func jsonNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			for name := range jsonNames(f.Type) {
+				names[name] = true
+			}
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
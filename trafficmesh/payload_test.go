@@ -0,0 +1,82 @@
+package trafficmesh
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadExtendedClaims(t *testing.T) {
+	enc := NewSignatureEncoder("secret", 0)
+	req := httptest.NewRequest(http.MethodGet, "http://example.org/", nil)
+
+	payload := NewSignaturePayload(req, "1", "2", "3", false)
+	payload.Context = ContextBranchDeploy
+	payload.Branch = "feature"
+	payload.POP = "fra1"
+	payload.Region = "eu-central-1"
+	payload.ClientIP = "203.0.113.7"
+	payload.RequestID = "01ABC"
+	sig, err := enc.EncodeSignature(payload)
+	require.NoError(t, err)
+	req.Header.Set(signatureHeader, sig)
+
+	decoded, err := NewSignatureDecoder("secret").DecodeSignature(req)
+	require.NoError(t, err)
+	assert.Equal(t, ContextBranchDeploy, decoded.Context)
+	assert.Equal(t, "feature", decoded.Branch)
+	assert.Equal(t, "fra1", decoded.POP)
+	assert.Equal(t, "eu-central-1", decoded.Region)
+	assert.Equal(t, "203.0.113.7", decoded.ClientIP)
+	assert.Equal(t, "01ABC", decoded.RequestID)
+	assert.Nil(t, decoded.Extra)
+
+	fields := decoded.Fields()
+	assert.Equal(t, "branch-deploy", fields["deploy_context"])
+	assert.Equal(t, "fra1", fields["edge_pop"])
+	assert.Equal(t, "203.0.113.7", fields["client_ip"])
+}
+
+func TestPayloadUnknownClaims(t *testing.T) {
+	req := signedRequest(t, "secret", jwt.MapClaims{
+		"sid":       "1",
+		"future":    "value",
+		"nested":    map[string]interface{}{"a": true},
+		"exp_extra": 12,
+	})
+
+	payload, err := NewSignatureDecoder("secret").DecodeSignature(req)
+	require.NoError(t, err)
+	assert.Equal(t, "1", payload.SiteID)
+	assert.Equal(t, map[string]interface{}{
+		"future":    "value",
+		"nested":    map[string]interface{}{"a": true},
+		"exp_extra": float64(12),
+	}, payload.Extra)
+
+	// unknown claims are kept when re-encoding, but can't override known ones
+	payload.Extra["sid"] = "override"
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	var out map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &out))
+	assert.Equal(t, "value", out["future"])
+	assert.Equal(t, "1", out["sid"])
+	assert.Equal(t, "http://example.org/", out["url"])
+}
+
+func TestPayloadFieldsOnlyIncludeSetClaims(t *testing.T) {
+	fields := (&SignaturePayload{SiteID: "1"}).Fields()
+	assert.Equal(t, logrus.Fields{"site_id": "1", "deploy_id": "", "account_id": ""}, fields)
+
+	var nilPayload *SignaturePayload
+	log := logrus.New()
+	assert.Equal(t, log, nilPayload.AddFields(log))
+}
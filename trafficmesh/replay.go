@@ -0,0 +1,93 @@
+package trafficmesh
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultReplayCacheSize is the number of token IDs a MemoryReplayCache holds when no size is given.
+const DefaultReplayCacheSize = 100000
+
+// ReplayCache records the IDs of tokens that were already used. Implementations backed by a
+// shared store allow enforcing one-time use across several instances of a service.
+type ReplayCache interface {
+	// CheckAndStore records id until expiry and reports whether it was already recorded.
+	CheckAndStore(id string, expiry time.Time) (seen bool, err error)
+}
+
+// MemoryReplayCache is a bounded in-memory ReplayCache. Expired IDs are dropped lazily, and
+// when the cache is full the oldest IDs are evicted even if they haven't expired yet.
+type MemoryReplayCache struct {
+	mtx     sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+type replayEntry struct {
+	id     string
+	expiry time.Time
+}
+
+var _ ReplayCache = &MemoryReplayCache{}
+
+// NewMemoryReplayCache constructs a MemoryReplayCache holding at most size IDs, or
+// DefaultReplayCacheSize when size is not positive.
+func NewMemoryReplayCache(size int) *MemoryReplayCache {
+	if size <= 0 {
+		size = DefaultReplayCacheSize
+	}
+	return &MemoryReplayCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// CheckAndStore implements ReplayCache
+func (c *MemoryReplayCache) CheckAndStore(id string, expiry time.Time) (bool, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	now := c.now()
+	if el, ok := c.entries[id]; ok {
+		if now.Before(el.Value.(*replayEntry).expiry) {
+			return true, nil
+		}
+		c.remove(el)
+	}
+
+	c.purge(now)
+	for c.order.Len() >= c.size {
+		c.remove(c.order.Front())
+	}
+
+	c.entries[id] = c.order.PushBack(&replayEntry{id: id, expiry: expiry})
+	return false, nil
+}
+
+// Len returns the number of IDs currently held.
+func (c *MemoryReplayCache) Len() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.order.Len()
+}
+
+// purge drops expired IDs from the front of the list. IDs are stored in insertion order, so
+// this stops at the first live one rather than scanning the whole cache.
+func (c *MemoryReplayCache) purge(now time.Time) {
+	for el := c.order.Front(); el != nil; el = c.order.Front() {
+		if now.Before(el.Value.(*replayEntry).expiry) {
+			return
+		}
+		c.remove(el)
+	}
+}
+
+func (c *MemoryReplayCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*replayEntry).id)
+}
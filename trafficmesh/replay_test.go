@@ -0,0 +1,37 @@
+package trafficmesh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryReplayCache(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	cache := NewMemoryReplayCache(2)
+	cache.now = func() time.Time { return now }
+
+	check := func(id string, ttl time.Duration) bool {
+		seen, err := cache.CheckAndStore(id, now.Add(ttl))
+		require.NoError(t, err)
+		return seen
+	}
+
+	assert.False(t, check("a", time.Minute))
+	assert.True(t, check("a", time.Minute))
+	assert.False(t, check("b", time.Second))
+	assert.Equal(t, 2, cache.Len())
+
+	// the cache is full, so the oldest id is evicted
+	assert.False(t, check("c", time.Minute))
+	assert.Equal(t, 2, cache.Len())
+	assert.False(t, check("a", time.Minute))
+
+	// expired ids are forgotten
+	assert.True(t, check("a", time.Second))
+	now = now.Add(2 * time.Minute)
+	assert.False(t, check("a", time.Minute))
+	assert.Equal(t, 1, cache.Len())
+}
@@ -4,50 +4,128 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
+	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v4"
 )
 
-const signatureHeader = "X-NF-Mesh-Signature"
+const (
+	signatureHeader = "X-NF-Mesh-Signature"
+	keyIDHeader     = "kid"
+)
 
 // SignatureDecoder decodes a signed traffic-mesh header.
 type SignatureDecoder struct {
-	secret string
+	keys       *Keyring
+	algorithms map[string]bool
+
+	requireExp bool
+	requireIat bool
+	audience   string
+	issuer     string
+	maxAge     time.Duration
+	leeway     time.Duration
+	replay     ReplayCache
+	urlMatch   URLMatch
+
+	requireBinding bool
+	maxBodySize    int64
+
+	now func() time.Time
+}
+
+// DecoderOption configures a SignatureDecoder.
+type DecoderOption func(*SignatureDecoder)
+
+// WithAlgorithms sets the signing algorithms the decoder accepts. By default only HS256
+// tokens are accepted. Independent of this list, a token is only ever verified with a
+// key of its own algorithm, and unsigned tokens using "none" are always rejected.
+func WithAlgorithms(algs ...string) DecoderOption {
+	return func(d *SignatureDecoder) {
+		d.algorithms = make(map[string]bool, len(algs))
+		for _, alg := range algs {
+			if alg != algorithmNone {
+				d.algorithms[alg] = true
+			}
+		}
+	}
 }
 
 // SignaturePayload represents the fields in a traffic-mesh signature.
 type SignaturePayload struct {
-	jwt.StandardClaims
+	jwt.RegisteredClaims
 	SiteID    string `json:"sid,omitempty"`
 	DeployID  string `json:"did,omitempty"`
 	AccountID string `json:"aid,omitempty"`
 	URL       string `json:"url,omitempty"`
 	Remapped  bool   `json:"remapped,omitempty"`
+
+	// set when the token is bound to the request method and body
+	Method     string `json:"mth,omitempty"`
+	BodyDigest string `json:"bdg,omitempty"`
+
+	// optional claims, older producers don't set them
+	Context   DeployContext `json:"ctx,omitempty"`
+	Branch    string        `json:"branch,omitempty"`
+	POP       string        `json:"pop,omitempty"`
+	Region    string        `json:"region,omitempty"`
+	ClientIP  string        `json:"cip,omitempty"`
+	RequestID string        `json:"rid,omitempty"`
+
+	// Extra holds the claims this version of the package doesn't know about, so they
+	// survive a decode and re-encode.
+	Extra map[string]interface{} `json:"-"`
 }
 
 // NewSignatureDecoder constructs a new SignatureDecoder. When secret is an empty string,
 // DecodeSignature is a no-op.
-func NewSignatureDecoder(secret string) *SignatureDecoder {
-	return &SignatureDecoder{
-		secret: secret,
+func NewSignatureDecoder(secret string, opts ...DecoderOption) *SignatureDecoder {
+	return NewSignatureDecoderWithKeyring(singleKeyring(secret), opts...)
+}
+
+// NewSignatureDecoderWithKeyring constructs a new SignatureDecoder that verifies tokens with the
+// key named by their kid header. Tokens without a kid are verified with the key with ID "".
+// When the keyring is empty, DecodeSignature is a no-op.
+func NewSignatureDecoderWithKeyring(keys *Keyring, opts ...DecoderOption) *SignatureDecoder {
+	d := &SignatureDecoder{
+		keys:        keys,
+		algorithms:  map[string]bool{AlgorithmHS256: true},
+		maxBodySize: DefaultMaxBodySize,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(d)
 	}
+	return d
 }
 
-// DecodeSignature decodes a traffic-mesh signature. When either the secret or the header is an
-// empty string, this method returns a nil payload and nil error.
+// DecodeSignature decodes a traffic-mesh signature. When either the keyring or the header is
+// empty, this method returns a nil payload and nil error. When the token is bound to the request
+// body, the body is read and verified before this method returns and req.Body is replaced with a
+// copy of it. Callers other than Middleware should close req.Body when they're done with it, to
+// remove the temporary file large bodies are copied to.
 func (d *SignatureDecoder) DecodeSignature(req *http.Request) (*SignaturePayload, error) {
-	if d.secret == "" || req.Header.Get(signatureHeader) == "" {
+	if d.keys.Empty() || req.Header.Get(signatureHeader) == "" {
 		return nil, nil
 	}
 
+	// the registered claims are validated by validateClaims, which supports leeway
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation(), jwt.WithValidMethods(d.validMethods()))
 	payload := new(SignaturePayload)
-	token, err := jwt.ParseWithClaims(req.Header.Get(signatureHeader), payload, func(token *jwt.Token) (interface{}, error) {
-		alg, ok := token.Method.(*jwt.SigningMethodHMAC)
-		if !ok || alg != jwt.SigningMethodHS256 {
+	token, err := parser.ParseWithClaims(req.Header.Get(signatureHeader), payload, func(token *jwt.Token) (interface{}, error) {
+		alg := token.Method.Alg()
+		if alg == algorithmNone || !d.algorithms[alg] {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(d.secret), nil
+		kid, _ := token.Header[keyIDHeader].(string)
+		key, ok := d.keys.VerificationKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id: %q", kid)
+		}
+		if key.Algorithm != alg {
+			return nil, fmt.Errorf("key %q can't verify %s tokens", kid, alg)
+		}
+		return key.verifyKey, nil
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode traffic mesh signature: %w", err)
@@ -55,17 +133,27 @@ func (d *SignatureDecoder) DecodeSignature(req *http.Request) (*SignaturePayload
 	if !token.Valid {
 		return nil, errors.New("invalid token")
 	}
+	if err := d.validateClaims(payload); err != nil {
+		return nil, err
+	}
 
-	payloadURL, err := url.Parse(payload.URL)
-	if err != nil {
+	if err := d.matchURL(payload.URL, req); err != nil {
 		return nil, err
 	}
-	if payloadURL.Host != req.Host {
-		return nil, fmt.Errorf("token host %s doesn't match request host: %s", payloadURL.Host, req.Host)
+	if err := d.checkBinding(req, payload); err != nil {
+		return nil, err
 	}
-	if payloadURI, reqURI := payloadURL.RequestURI(), req.URL.RequestURI(); payloadURI != reqURI {
-		return nil, fmt.Errorf("token uri %s doesn't match request uri: %s", payloadURI, reqURI)
+	if err := d.checkReplay(payload); err != nil {
+		return nil, err
 	}
 
 	return payload, nil
 }
+
+func (d *SignatureDecoder) validMethods() []string {
+	methods := make([]string, 0, len(d.algorithms))
+	for alg := range d.algorithms {
+		methods = append(methods, alg)
+	}
+	return methods
+}
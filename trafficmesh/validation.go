@@ -0,0 +1,149 @@
+package trafficmesh
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors returned by DecodeSignature when the token's time based claims or its ID are rejected.
+// They are wrapped, use errors.Is to check for them.
+var (
+	ErrTokenExpired     = errors.New("traffic mesh token is expired")
+	ErrTokenNotYetValid = errors.New("traffic mesh token is not valid yet")
+	ErrTokenReplayed    = errors.New("traffic mesh token was already used")
+	ErrMissingClaim     = errors.New("traffic mesh token is missing a required claim")
+	ErrInvalidAudience  = errors.New("traffic mesh token has an invalid audience")
+	ErrInvalidIssuer    = errors.New("traffic mesh token has an invalid issuer")
+)
+
+// defaultReplayTTL is how long a token ID is remembered when the token has neither
+// an expiry nor a max age to derive it from.
+const defaultReplayTTL = 10 * time.Minute
+
+// RequireExpiry rejects tokens without an exp claim.
+func RequireExpiry() DecoderOption {
+	return func(d *SignatureDecoder) {
+		d.requireExp = true
+	}
+}
+
+// RequireIssuedAt rejects tokens without an iat claim.
+func RequireIssuedAt() DecoderOption {
+	return func(d *SignatureDecoder) {
+		d.requireIat = true
+	}
+}
+
+// WithMaxAge rejects tokens that were issued longer than maxAge ago, independent of their
+// expiry. It implies RequireIssuedAt.
+func WithMaxAge(maxAge time.Duration) DecoderOption {
+	return func(d *SignatureDecoder) {
+		d.maxAge = maxAge
+		d.requireIat = true
+	}
+}
+
+// WithLeeway allows for clock skew between the producer and the decoder when checking
+// the exp, nbf and iat claims.
+func WithLeeway(leeway time.Duration) DecoderOption {
+	return func(d *SignatureDecoder) {
+		d.leeway = leeway
+	}
+}
+
+// RequireAudience rejects tokens whose aud claim doesn't contain aud.
+func RequireAudience(aud string) DecoderOption {
+	return func(d *SignatureDecoder) {
+		d.audience = aud
+	}
+}
+
+// RequireIssuer rejects tokens whose iss claim isn't iss.
+func RequireIssuer(iss string) DecoderOption {
+	return func(d *SignatureDecoder) {
+		d.issuer = iss
+	}
+}
+
+// WithReplayCache enforces one-time use of tokens by recording their jti claim in cache.
+// Tokens without a jti are rejected.
+func WithReplayCache(cache ReplayCache) DecoderOption {
+	return func(d *SignatureDecoder) {
+		d.replay = cache
+	}
+}
+
+func (d *SignatureDecoder) validateClaims(payload *SignaturePayload) error {
+	if d.issuer != "" && payload.Issuer != d.issuer {
+		return fmt.Errorf("%w: %q", ErrInvalidIssuer, payload.Issuer)
+	}
+	if d.audience != "" && !containsString(payload.Audience, d.audience) {
+		return fmt.Errorf("%w: %q", ErrInvalidAudience, []string(payload.Audience))
+	}
+	return d.validateTimes(payload)
+}
+
+func (d *SignatureDecoder) validateTimes(payload *SignaturePayload) error {
+	now := d.now()
+
+	if payload.ExpiresAt == nil && d.requireExp {
+		return fmt.Errorf("%w: exp", ErrMissingClaim)
+	}
+	if payload.IssuedAt == nil && d.requireIat {
+		return fmt.Errorf("%w: iat", ErrMissingClaim)
+	}
+
+	if exp := payload.ExpiresAt; exp != nil && now.After(exp.Add(d.leeway)) {
+		return fmt.Errorf("%w: expired at %d", ErrTokenExpired, exp.Unix())
+	}
+	if nbf := payload.NotBefore; nbf != nil && now.Add(d.leeway).Before(nbf.Time) {
+		return fmt.Errorf("%w: not before %d", ErrTokenNotYetValid, nbf.Unix())
+	}
+	if iat := payload.IssuedAt; iat != nil {
+		if now.Add(d.leeway).Before(iat.Time) {
+			return fmt.Errorf("%w: issued at %d", ErrTokenNotYetValid, iat.Unix())
+		}
+		if d.maxAge > 0 && now.After(iat.Add(d.maxAge+d.leeway)) {
+			return fmt.Errorf("%w: issued at %d exceeds max age %s", ErrTokenExpired, iat.Unix(), d.maxAge)
+		}
+	}
+	return nil
+}
+
+func (d *SignatureDecoder) checkReplay(payload *SignaturePayload) error {
+	if d.replay == nil {
+		return nil
+	}
+	if payload.ID == "" {
+		return fmt.Errorf("%w: jti", ErrMissingClaim)
+	}
+
+	var expiry time.Time
+	switch {
+	case payload.ExpiresAt != nil:
+		expiry = payload.ExpiresAt.Time
+	case d.maxAge > 0:
+		expiry = payload.IssuedAt.Add(d.maxAge)
+	default:
+		expiry = d.now().Add(defaultReplayTTL)
+	}
+
+	seen, err := d.replay.CheckAndStore(payload.ID, expiry.Add(d.leeway))
+	if err != nil {
+		return fmt.Errorf("failed to check traffic mesh token for replay: %w", err)
+	}
+	if seen {
+		return fmt.Errorf("%w: jti %s", ErrTokenReplayed, payload.ID)
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, el := range list {
+		if el == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package trafficmesh
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeValidation(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	at := func(d time.Duration) int64 { return now.Add(d).Unix() }
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		opts   []DecoderOption
+		err    error
+	}{
+		{"no time claims", jwt.MapClaims{}, nil, nil},
+		{"valid", jwt.MapClaims{"iat": at(-time.Second), "nbf": at(-time.Second), "exp": at(time.Minute)}, nil, nil},
+		{"expired", jwt.MapClaims{"exp": at(-time.Second)}, nil, ErrTokenExpired},
+		{"expired within leeway", jwt.MapClaims{"exp": at(-time.Second)}, []DecoderOption{WithLeeway(5 * time.Second)}, nil},
+		{"not yet valid", jwt.MapClaims{"nbf": at(time.Second)}, nil, ErrTokenNotYetValid},
+		{"not yet valid within leeway", jwt.MapClaims{"nbf": at(time.Second)}, []DecoderOption{WithLeeway(5 * time.Second)}, nil},
+		{"issued in the future", jwt.MapClaims{"iat": at(time.Minute)}, []DecoderOption{WithLeeway(5 * time.Second)}, ErrTokenNotYetValid},
+		{"missing exp", jwt.MapClaims{}, []DecoderOption{RequireExpiry()}, ErrMissingClaim},
+		{"missing iat", jwt.MapClaims{"exp": at(time.Minute)}, []DecoderOption{RequireIssuedAt()}, ErrMissingClaim},
+		{"max age implies iat", jwt.MapClaims{}, []DecoderOption{WithMaxAge(time.Minute)}, ErrMissingClaim},
+		{"older than max age", jwt.MapClaims{"iat": at(-2 * time.Minute), "exp": at(time.Hour)}, []DecoderOption{WithMaxAge(time.Minute)}, ErrTokenExpired},
+		{"within max age", jwt.MapClaims{"iat": at(-30 * time.Second)}, []DecoderOption{WithMaxAge(time.Minute)}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dec := NewSignatureDecoder("secret", test.opts...)
+			dec.now = func() time.Time { return now }
+
+			_, err := dec.DecodeSignature(signedRequest(t, "secret", test.claims))
+			if test.err == nil {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, test.err), "expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestReplayProtection(t *testing.T) {
+	dec := NewSignatureDecoder("secret", WithReplayCache(NewMemoryReplayCache(0)))
+
+	claims := jwt.MapClaims{"jti": "abc", "exp": time.Now().Add(time.Minute).Unix()}
+	_, err := dec.DecodeSignature(signedRequest(t, "secret", claims))
+	require.NoError(t, err)
+
+	_, err = dec.DecodeSignature(signedRequest(t, "secret", claims))
+	assert.True(t, errors.Is(err, ErrTokenReplayed), "unexpected error %v", err)
+
+	_, err = dec.DecodeSignature(signedRequest(t, "secret", jwt.MapClaims{}))
+	assert.True(t, errors.Is(err, ErrMissingClaim), "unexpected error %v", err)
+
+	// tokens from the encoder carry a unique jti
+	enc := NewSignatureEncoder("secret", 0)
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "http://example.org/", nil)
+		_, err := enc.SignRequest(req, "1", "2", "3", false)
+		require.NoError(t, err)
+		_, err = dec.DecodeSignature(req)
+		require.NoError(t, err)
+	}
+}
+
+func signedRequest(t *testing.T, secret string, claims jwt.MapClaims) *http.Request {
+	claims["url"] = "http://example.org/"
+	sig, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.org/", nil)
+	req.Header.Set(signatureHeader, sig)
+	return req
+}